@@ -0,0 +1,64 @@
+// Package main: служебная команда управления схемой БД.
+//
+//	todo migrate status      — список миграций и их состояние
+//	todo migrate up [версия] — применить миграции до версии (по умолчанию — до последней)
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"todo/pkg/db"
+)
+
+// runMigrate выполняет команду migrate для базы PostgreSQL dsn (если задана)
+// или SQLite-файла dbFile.
+func runMigrate(dsn, dbFile string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: todo migrate status | todo migrate up [version]")
+	}
+	d, err := db.Connect(dsn, dbFile)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	switch args[0] {
+	case "status":
+		states, err := db.MigrationStatus(d)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range states {
+			applied := "pending"
+			if s.Applied {
+				applied = s.AppliedAt
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+	case "up":
+		target := 0
+		if len(args) > 1 {
+			if target, err = strconv.Atoi(args[1]); err != nil || target <= 0 {
+				return fmt.Errorf("bad version %q", args[1])
+			}
+		}
+		if err := db.Migrate(d, target); err != nil {
+			return err
+		}
+		v, err := db.CurrentVersion(d)
+		if err != nil {
+			return err
+		}
+		fmt.Println("schema version:", v)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
@@ -3,8 +3,13 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,23 +19,25 @@ import (
 // taskHandler — общий роутер для пути /api/task.
 // Внутри по HTTP-методу вызываются соответствующие под-обработчики:
 // POST (add), GET (get by id), PUT (update), DELETE (remove).
-func taskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) taskHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
-		addTaskHandler(w, r)
+		a.addTaskHandler(w, r)
 	case http.MethodGet:
-		getTaskHandler(w, r)
+		a.getTaskHandler(w, r)
 	case http.MethodPut:
-		updateTaskHandler(w, r)
+		a.updateTaskHandler(w, r)
 	case http.MethodDelete:
-		deleteTaskHandler(w, r)
+		a.deleteTaskHandler(w, r)
 	default:
 		writeJSON(w, map[string]string{"error": "method not allowed"})
 	}
 }
 
 // addTaskHandler обрабатывает POST /api/task.
-func addTaskHandler(w http.ResponseWriter, r *http.Request) {
+// С флагом ?parse_repeat=1 поле repeat может быть фразой ("каждый понедельник"),
+// которая переводится в каноническое правило через ParseRepeatPhrase.
+func (a *API) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
@@ -45,20 +52,62 @@ func addTaskHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "empty title")
 		return
 	}
+	// время создания проставляет хранилище, дату по расписанию — checkDate
+	t.Created, t.Scheduled = "", ""
+	if r.URL.Query().Get("parse_repeat") == "1" && t.Repeat != "" {
+		// уже каноническое правило оставляем как есть
+		if _, err := NextDate(time.Now(), time.Now().Format(dateFmt), t.Repeat); err != nil {
+			repeat, err := parseRepeatText(t.Repeat)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "bad repeat phrase")
+				return
+			}
+			t.Repeat = repeat
+		}
+	}
 	if err := checkDate(t); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	id, err := db.AddTask(t)
+	if err := checkTags(t); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkPriority(t); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkChecklist(t); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkBlockedBy(t); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if code, err := a.checkTaskProject(t, nil); err != nil {
+		writeError(w, code, err.Error())
+		return
+	}
+	id, err := a.store.AddTask(t)
+	if errors.Is(err, db.ErrBlockerNotFound) || errors.Is(err, db.ErrDependencyCycle) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "db insert error")
 		return
 	}
+	after, err := a.store.GetTask(fmt.Sprint(id))
+	if err != nil {
+		after = nil
+	}
+	a.audit(r, auditCreate, id, nil, after)
 	writeJSON(w, map[string]string{"id": fmt.Sprint(id)})
 }
 
 // getTaskHandler — GET /api/task?id=<число>
-func getTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) getTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
@@ -68,7 +117,7 @@ func getTaskHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "no id")
 		return
 	}
-	t, err := db.GetTask(id)
+	t, err := a.store.GetTask(id)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "task not found")
 		return
@@ -77,13 +126,18 @@ func getTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // updateTaskHandler — PUT /api/task
-func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "json parse error")
+		return
+	}
 	in := new(db.Task)
-	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+	if err := json.Unmarshal(body, in); err != nil {
 		writeError(w, http.StatusBadRequest, "json parse error")
 		return
 	}
@@ -91,19 +145,68 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "bad id")
 		return
 	}
+	// Поля, которых нет в запросе (например, repeat_until от старого фронтенда),
+	// сохраняют текущие значения: накладываем JSON поверх задачи из БД.
+	before, err := a.store.GetTask(fmt.Sprint(in.ID))
+	if err == nil {
+		overlay := cloneTask(before)
+		// пункты чек-листа в запросе заменяют прежние, а не дополняют их поля
+		if in.Checklist != nil {
+			overlay.Checklist = nil
+		}
+		in = overlay
+		if err := json.Unmarshal(body, in); err != nil {
+			writeError(w, http.StatusBadRequest, "json parse error")
+			return
+		}
+	}
 	if in.Title == "" {
 		writeError(w, http.StatusBadRequest, "empty title")
 		return
 	}
+	// поля, которые ведёт сервер, клиент не меняет
+	in.Created, in.Deleted, in.Snippet, in.Progress, in.Blocked = "", "", "", "", false
+	// дата по расписанию не задаётся клиентом и теряет смысл, если дату
+	// или правило повторения изменили
+	in.Scheduled = ""
+	if before != nil && in.Date == before.Date && in.Repeat == before.Repeat {
+		in.Scheduled = before.Scheduled
+	}
 	if err := checkDate(in); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if err := db.UpdateTask(in); err != nil {
+	if err := checkTags(in); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkPriority(in); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkChecklist(in); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkBlockedBy(in); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// перенос в другой проект — тот же PUT с полем project_id
+	if code, err := a.checkTaskProject(in, before); err != nil {
+		writeError(w, code, err.Error())
+		return
+	}
+	err = a.store.UpdateTask(in)
+	if errors.Is(err, db.ErrBlockerNotFound) || errors.Is(err, db.ErrDependencyCycle) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
 		writeError(w, http.StatusNotFound, "update error")
 		return
 	}
-	writeJSON(w, map[string]any{})
+	a.audited(w, r, auditUpdate, in.ID, before)
 }
 
 // checkDate — единая проверка/нормализация даты и repeat.
@@ -122,9 +225,12 @@ func checkDate(tk *db.Task) error {
 		return fmt.Errorf("bad date format")
 	}
 
-	var next string
+	if err := checkRepeatLimits(tk); err != nil {
+		return err
+	}
+	var next, scheduled string
 	if tk.Repeat != "" {
-		next, err = NextDate(now, tk.Date, tk.Repeat)
+		next, scheduled, err = nextDateSkipping(now, tk)
 		if err != nil {
 			return fmt.Errorf("bad repeat")
 		}
@@ -135,14 +241,82 @@ func checkDate(tk *db.Task) error {
 		if tk.Repeat == "" {
 			tk.Date = now.Format(dateFmt)
 		} else {
-			tk.Date = next
+			tk.Date, tk.Scheduled = next, scheduled
+		}
+	}
+	// сама дата задачи попала в исключения — переносим на следующее повторение
+	if slices.Contains(tk.Exdates, tk.Date) {
+		if tk.Date, tk.Scheduled, err = skipExdate(tk); err != nil {
+			return err
 		}
 	}
+	if tk.RepeatUntil != "" && tk.Date > tk.RepeatUntil {
+		return errNoOccurrences
+	}
+	return nil
+}
+
+// checkRepeatLimits проверяет настройки серии: repeat_until — дата 20060102,
+// repeat_count — неотрицательное число, repeat_from — "schedule" или "done";
+// все они имеют смысл только вместе с repeat.
+func checkRepeatLimits(tk *db.Task) error {
+	if tk.RepeatUntil != "" {
+		if _, err := time.Parse(dateFmt, tk.RepeatUntil); err != nil {
+			return fmt.Errorf("bad repeat_until")
+		}
+	}
+	if tk.RepeatCount < 0 || tk.RepeatDone < 0 {
+		return fmt.Errorf("bad repeat_count")
+	}
+	switch tk.RepeatFrom {
+	case "", "schedule":
+		tk.RepeatFrom = "" // отсчёт от расписания — значение по умолчанию
+	case db.RepeatFromDone:
+	default:
+		return fmt.Errorf("bad repeat_from")
+	}
+	if tk.Repeat == "" && (tk.RepeatUntil != "" || tk.RepeatCount > 0 || tk.RepeatFrom != "") {
+		return fmt.Errorf("repeat limits without repeat")
+	}
+	for _, d := range tk.Exdates {
+		if _, err := time.Parse(dateFmt, d); err != nil {
+			return fmt.Errorf("bad exdate")
+		}
+	}
+	if tk.Repeat == "" && len(tk.Exdates) > 0 {
+		return fmt.Errorf("exdates without repeat")
+	}
+	return nil
+}
+
+// checkBlockedBy проверяет id задач в blocked_by (положительные числа);
+// существование задач и отсутствие циклов проверяет хранилище.
+func checkBlockedBy(tk *db.Task) error {
+	for i, s := range tk.BlockedBy {
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("bad blocked_by")
+		}
+		tk.BlockedBy[i] = strconv.FormatInt(n, 10)
+	}
+	return nil
+}
+
+// checkPriority приводит приоритет к нижнему регистру и проверяет его:
+// low, medium, high, urgent или none (то же, что пустой — без приоритета).
+func checkPriority(tk *db.Task) error {
+	tk.Priority = strings.ToLower(strings.TrimSpace(tk.Priority))
+	if tk.Priority == "none" {
+		tk.Priority = ""
+	}
+	if !slices.Contains(db.Priorities, tk.Priority) {
+		return fmt.Errorf("bad priority")
+	}
 	return nil
 }
 
 // deleteTaskHandler — DELETE /api/task?id=...
-func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
@@ -152,15 +326,21 @@ func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "no id")
 		return
 	}
-	if err := db.DeleteTask(id); err != nil {
+	before, err := a.store.GetTask(id)
+	if err != nil {
 		writeError(w, http.StatusNotFound, "delete error")
 		return
 	}
-	writeJSON(w, map[string]any{})
+	if err := a.store.DeleteTask(id); err != nil {
+		writeError(w, http.StatusNotFound, "delete error")
+		return
+	}
+	a.audited(w, r, auditDelete, before.ID, before)
 }
 
-// taskDoneHandler — POST /api/task/done?id=...
-func taskDoneHandler(w http.ResponseWriter, r *http.Request) {
+// taskDoneHandler — POST /api/task/done?id=...[&force=1]
+// Задачу с открытыми блокирующими задачами (blocked) без force=1 не выполняет: 409.
+func (a *API) taskDoneHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
@@ -170,31 +350,62 @@ func taskDoneHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "no id")
 		return
 	}
-	t, err := db.GetTask(id)
+	t, err := a.store.GetTask(id)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "task not found")
 		return
 	}
+	// задачу, которую блокируют открытые задачи, выполнить можно только явно
+	if t.Blocked && r.URL.Query().Get("force") != "1" {
+		writeError(w, http.StatusConflict, "task is blocked")
+		return
+	}
 	if strings.TrimSpace(t.Repeat) == "" {
-		if err := db.DeleteTask(id); err != nil {
+		if err := a.store.DeleteTask(id); err != nil {
 			writeError(w, http.StatusNotFound, "delete error")
 			return
 		}
-		writeJSON(w, map[string]any{})
+		a.completed(w, r, t)
 		return
 	}
 	now := time.Now()
 	y, m, d := now.Date()
 	now = time.Date(y, m, d, 0, 0, 0, 0, time.Local)
 
-	next, err := NextDate(now, t.Date, t.Repeat)
+	// в режиме repeat_from=done интервал отсчитывается от дня выполнения
+	series := *t
+	if t.RepeatFrom == db.RepeatFromDone {
+		series.Date, series.Scheduled = now.Format(dateFmt), ""
+	}
+	next, scheduled, err := nextOccurrence(now, &series)
+	if errors.Is(err, errNoOccurrences) {
+		// серия исчерпана — задача больше не повторяется
+		if err := a.store.DeleteTask(id); err != nil {
+			writeError(w, http.StatusNotFound, "delete error")
+			return
+		}
+		a.completed(w, r, t)
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "bad repeat")
 		return
 	}
-	if err := db.UpdateDate(next, id); err != nil {
+	if err := a.store.CompleteOccurrence(next, scheduled, id); err != nil {
 		writeError(w, http.StatusNotFound, "update error")
 		return
 	}
-	writeJSON(w, map[string]any{})
+	a.completed(w, r, t)
+}
+
+// completed записывает выполнение задачи t (в том виде, в каком она была
+// до отметки) в историю и журнал изменений и отвечает на запрос /api/task/done.
+// Задача к этому моменту уже отмечена, поэтому ошибка записи истории
+// только попадает в лог сервера (как и ошибка журнала, см. addAudit).
+func (a *API) completed(w http.ResponseWriter, r *http.Request, t *db.Task) {
+	err := a.store.AddCompletion(&db.Completion{TaskID: t.ID, Title: t.Title, Date: t.Date})
+	if err != nil {
+		log.Printf("history error: %d: %v\n", t.ID, err)
+	}
+	a.audited(w, r, auditDone, t.ID, t)
 }
@@ -0,0 +1,166 @@
+// Package api: задачи по дням за период (календарь и повестка).
+// GET /api/agenda?from=20060102&to=20060102 | ?week=20060102 | ?month=200601
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"todo/pkg/db"
+)
+
+// maxAgendaDays — наибольшая длина периода /api/agenda в днях.
+const maxAgendaDays = 366
+
+// agendaTask — задача в дне повестки. Virtual — это будущее повторение серии,
+// вычисленное по правилу: сама задача (и её date) хранится на другой день.
+type agendaTask struct {
+	*db.Task
+	Virtual bool `json:"virtual,omitempty"`
+}
+
+// agendaDay — задачи одного дня.
+type agendaDay struct {
+	Date  string       `json:"date"`
+	Tasks []agendaTask `json:"tasks"`
+}
+
+// agendaResp — форма ответа: период и непустые дни по возрастанию даты.
+type agendaResp struct {
+	From string      `json:"from"`
+	To   string      `json:"to"`
+	Days []agendaDay `json:"days"`
+}
+
+// agendaRange разбирает период запроса: from и to включительно, неделя
+// с понедельника, содержащая week, или календарный месяц month (200601).
+func agendaRange(r *http.Request) (from, to time.Time, err error) {
+	week := strings.TrimSpace(r.FormValue("week"))
+	month := strings.TrimSpace(r.FormValue("month"))
+	fromStr := strings.TrimSpace(r.FormValue("from"))
+	toStr := strings.TrimSpace(r.FormValue("to"))
+
+	switch {
+	case week != "" && month == "" && fromStr == "" && toStr == "":
+		d, err := time.Parse(dateFmt, week)
+		if err != nil {
+			return from, to, errors.New("bad week")
+		}
+		from = d.AddDate(0, 0, -(int(d.Weekday())+6)%7)
+		return from, from.AddDate(0, 0, 6), nil
+	case month != "" && week == "" && fromStr == "" && toStr == "":
+		d, err := time.Parse("200601", month)
+		if err != nil {
+			return from, to, errors.New("bad month")
+		}
+		return d, d.AddDate(0, 1, -1), nil
+	case week == "" && month == "" && fromStr != "" && toStr != "":
+		if from, err = time.Parse(dateFmt, fromStr); err != nil {
+			return from, to, errors.New("bad from")
+		}
+		if to, err = time.Parse(dateFmt, toStr); err != nil {
+			return from, to, errors.New("bad to")
+		}
+		if to.Before(from) {
+			return from, to, errors.New("from is after to")
+		}
+		if to.Sub(from) >= maxAgendaDays*24*time.Hour {
+			return from, to, errors.New("range is too long")
+		}
+		return from, to, nil
+	}
+	return from, to, errors.New("need from and to, week or month")
+}
+
+// allTasks — все задачи, подходящие под q (постранично через store.Tasks).
+func (a *API) allTasks(q db.Query) ([]*db.Task, error) {
+	var out []*db.Task
+	p := db.Page{Limit: maxTasksLimit}
+	for {
+		page, err := a.store.Tasks(q, p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, page.Tasks...)
+		if page.Next == nil {
+			return out, nil
+		}
+		p.After = page.Next
+	}
+}
+
+// taskDates — даты задачи t в периоде [from, to]: её собственная дата
+// и, для повторяющейся задачи, следующие повторения серии (см. Occurrences).
+// Серия с неразбираемым правилом даёт только собственную дату.
+func taskDates(t *db.Task, from, to time.Time) []string {
+	var out []string
+	if t.Date >= from.Format(dateFmt) && t.Date <= to.Format(dateFmt) {
+		out = append(out, t.Date)
+	}
+	start, err := time.Parse(dateFmt, t.Date)
+	if t.Repeat == "" || err != nil || start.After(to) {
+		return out
+	}
+	// повторения идут строго по возрастанию, поэтому их не больше, чем дней до to
+	next, err := Occurrences(start, t, int(to.Sub(start).Hours()/24)+1, to)
+	if err != nil {
+		return out
+	}
+	for _, d := range next {
+		if d >= from.Format(dateFmt) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// agendaHandler — GET /api/agenda: задачи периода, сгруппированные по дням.
+// Повторяющиеся задачи попадают и на дни будущих повторений (virtual: true).
+// Дни без задач не выводятся.
+func (a *API) agendaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	from, to, err := agendaRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// разовые задачи — только из периода, серии — все, что начались до его конца
+	once, err := a.allTasks(db.Query{From: from.Format(dateFmt), To: to.Format(dateFmt), Repeat: db.RepeatNo})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db select error")
+		return
+	}
+	series, err := a.allTasks(db.Query{To: to.Format(dateFmt), Repeat: db.RepeatYes})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db select error")
+		return
+	}
+
+	byDay := map[string][]agendaTask{}
+	for _, t := range append(once, series...) {
+		for _, d := range taskDates(t, from, to) {
+			byDay[d] = append(byDay[d], agendaTask{Task: t, Virtual: d != t.Date})
+		}
+	}
+	resp := agendaResp{From: from.Format(dateFmt), To: to.Format(dateFmt), Days: make([]agendaDay, 0, len(byDay))}
+	for d, tasks := range byDay {
+		// в один день сначала важные задачи
+		sort.Slice(tasks, func(i, j int) bool {
+			pi, pj := db.PriorityRank(tasks[i].Priority), db.PriorityRank(tasks[j].Priority)
+			if pi != pj {
+				return pi > pj
+			}
+			return tasks[i].ID < tasks[j].ID
+		})
+		resp.Days = append(resp.Days, agendaDay{Date: d, Tasks: tasks})
+	}
+	sort.Slice(resp.Days, func(i, j int) bool { return resp.Days[i].Date < resp.Days[j].Date })
+	writeJSON(w, resp)
+}
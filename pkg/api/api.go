@@ -2,16 +2,68 @@
 // Здесь связываем URL с обработчиками и навешиваем middleware (auth).
 package api
 
-import "net/http"
+import (
+	"net/http"
+	"time"
 
-// Init регистрирует все маршруты API в стандартном mux.
+	"todo/pkg/db"
+)
+
+// API — HTTP-обработчики планировщика поверх конкретного хранилища задач.
+// Экземпляры независимы: в одном процессе можно поднять несколько API
+// с разными хранилищами и паролями.
+type API struct {
+	store    db.TaskStore
+	password string // пустая строка = аутентификация выключена
+	// users — пароли именованных пользователей, admins — у кого из них
+	// есть доступ к /api/admin/... (TODO_USERS, TODO_ADMINS)
+	users  map[string]string
+	admins map[string]bool
+	// trashRetention — сколько задачи хранятся в корзине (0 — без автоочистки)
+	trashRetention time.Duration
+}
+
+// New создаёт API поверх store. Пароль берётся из TODO_PASSWORD,
+// пользователи — из TODO_USERS и TODO_ADMINS,
+// срок хранения корзины — из TODO_TRASH_DAYS,
+// календарь праздников — из TODO_HOLIDAYS/TODO_HOLIDAYS_COUNTRY.
+func New(store db.TaskStore) *API {
+	setCalendarFromEnv()
+	a := &API{store: store, password: passwordFromEnv(), trashRetention: trashRetentionFromEnv()}
+	a.usersFromEnv()
+	return a
+}
+
+// SetPassword задаёт пароль этого экземпляра вместо TODO_PASSWORD
+// (пустая строка выключает аутентификацию).
+func (a *API) SetPassword(p string) {
+	a.password = p
+}
+
+// Register регистрирует все маршруты API в mux.
 // /api/signin — вход (выдача JWT), остальные — защищённые (auth(...)).
-func Init() {
-	setPasswordFromEnv() // ← добавили
+func (a *API) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/signin", a.signinHandler)
+	mux.HandleFunc("/api/task", a.auth(a.taskHandler))
+	mux.HandleFunc("/api/tasks", a.auth(a.tasksHandler))
+	mux.HandleFunc("/api/task/done", a.auth(a.taskDoneHandler))
+	mux.HandleFunc("/api/task/exdate", a.auth(a.exdateHandler))
+	mux.HandleFunc("/api/task/checklist", a.auth(a.checklistHandler))
+	mux.HandleFunc("/api/agenda", a.auth(a.agendaHandler))
+	mux.HandleFunc("/api/trash", a.auth(a.trashHandler))
+	mux.HandleFunc("/api/trash/restore", a.auth(a.restoreHandler))
+	mux.HandleFunc("/api/history", a.auth(a.historyHandler))
+	mux.HandleFunc("/api/admin/audit", a.auth(a.admin(a.auditHandler)))
+	mux.HandleFunc("/api/tags", a.auth(a.tagsHandler))
+	mux.HandleFunc("/api/projects", a.auth(a.projectsHandler))
+	mux.HandleFunc("/api/nextdate", nextDateHandler)
+	mux.HandleFunc("/api/occurrences", occurrencesHandler)
+	mux.HandleFunc("/api/repeat/parse", repeatParseHandler)
+}
 
-	http.HandleFunc("/api/signin", signinHandler)
-	http.HandleFunc("/api/task", auth(taskHandler))
-	http.HandleFunc("/api/tasks", auth(tasksHandler))
-	http.HandleFunc("/api/task/done", auth(taskDoneHandler))
-	http.HandleFunc("/api/nextdate", nextDateHandler)
+// Handler возвращает отдельный mux, в котором зарегистрирован только API.
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	a.Register(mux)
+	return mux
 }
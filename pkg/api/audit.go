@@ -0,0 +1,224 @@
+// Package api: журнал изменений задач.
+// GET /api/admin/audit[?task_id=...][&action=...][&principal=...][&from=...][&to=...][&limit=N]
+// GET /api/admin/audit?format=jsonl — выгрузка всего журнала (под те же фильтры)
+// в формате JSON Lines, от старых записей к новым.
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo/pkg/db"
+)
+
+// Действия в журнале изменений.
+const (
+	auditCreate        = "create"
+	auditUpdate        = "update"
+	auditDelete        = "delete"
+	auditDone          = "done"
+	auditExdateAdd     = "exdate_add"
+	auditExdateDelete  = "exdate_delete"
+	auditCheck         = "check"
+	auditRestore       = "restore"
+	auditPurge         = "purge"
+	auditTagRename     = "tag_rename"
+	auditTagDelete     = "tag_delete"
+	auditProjectDelete = "project_delete"
+)
+
+// defaultAuditLimit — сколько записей журнала отдавать по умолчанию.
+const defaultAuditLimit = 100
+
+// auditItem — запись журнала в ответе: diff выводится как JSON-объект.
+type auditItem struct {
+	*db.AuditEntry
+	Diff json.RawMessage `json:"diff"`
+}
+
+// auditResp — форма ответа: {"entries":[...]}, сначала новые.
+type auditResp struct {
+	Entries []auditItem `json:"entries"`
+}
+
+// taskDiff — JSON изменившихся полей задачи {"поле": [было, стало]};
+// отсутствующее поле (или вся задача, если before/after == nil) — null.
+func taskDiff(before, after *db.Task) string {
+	fields := func(t *db.Task) map[string]any {
+		m := map[string]any{}
+		if t != nil {
+			b, _ := json.Marshal(t)
+			_ = json.Unmarshal(b, &m)
+		}
+		delete(m, "snippet")
+		delete(m, "progress") // следует из checklist
+		delete(m, "blocked")  // и blocked — из blocked_by
+		return m
+	}
+	was, now := fields(before), fields(after)
+	diff := map[string][2]any{}
+	for k, v := range was {
+		if !reflect.DeepEqual(v, now[k]) {
+			diff[k] = [2]any{v, now[k]}
+		}
+	}
+	for k, v := range now {
+		if _, ok := was[k]; !ok {
+			diff[k] = [2]any{nil, v}
+		}
+	}
+	b, _ := json.Marshal(diff)
+	return string(b)
+}
+
+// remoteHost — адрес клиента без порта.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// addAudit добавляет запись журнала о действии запроса r. Журнал пишется
+// после того, как изменение уже сохранено, поэтому неудачная запись не
+// превращает успешный запрос в ошибку, а только попадает в лог сервера.
+func (a *API) addAudit(r *http.Request, e *db.AuditEntry) {
+	e.Principal, e.Remote = principal(r), remoteHost(r)
+	if err := a.store.AddAudit(e); err != nil {
+		log.Printf("audit error: %s %d: %v\n", e.Action, e.TaskID, err)
+	}
+}
+
+// audit записывает в журнал действие action над задачей id, выполненное
+// запросом r; before и after — задача до и после изменения (nil — её нет).
+func (a *API) audit(r *http.Request, action string, id int64, before, after *db.Task) {
+	a.addAudit(r, &db.AuditEntry{Action: action, TaskID: id, Diff: taskDiff(before, after)})
+}
+
+// cloneTask — копия задачи, которую не затронут изменения исходной.
+func cloneTask(t *db.Task) *db.Task {
+	c := *t
+	c.Exdates = slices.Clone(t.Exdates)
+	c.Tags = slices.Clone(t.Tags)
+	c.Checklist = slices.Clone(t.Checklist)
+	c.BlockedBy = slices.Clone(t.BlockedBy)
+	return &c
+}
+
+// audited записывает действие над задачей id в журнал и отвечает {}.
+// Состояние задачи после действия перечитывается из хранилища
+// (nil — задачи больше нет или она в корзине).
+func (a *API) audited(w http.ResponseWriter, r *http.Request, action string, id int64, before *db.Task) {
+	after, err := a.store.GetTask(strconv.FormatInt(id, 10))
+	if err != nil {
+		after = nil
+	}
+	a.audit(r, action, id, before, after)
+	writeJSON(w, map[string]any{})
+}
+
+// auditFilter разбирает параметры запроса журнала; from и to — дни
+// (20060102, включительно, по местному времени).
+func auditFilter(r *http.Request) (db.AuditFilter, string, bool) {
+	f := db.AuditFilter{
+		Action:    strings.TrimSpace(r.FormValue("action")),
+		Principal: strings.TrimSpace(r.FormValue("principal")),
+		Limit:     defaultAuditLimit,
+	}
+	if s := strings.TrimSpace(r.FormValue("task_id")); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			return f, "bad task_id", false
+		}
+		f.TaskID = n
+	}
+	if s := strings.TrimSpace(r.FormValue("from")); s != "" {
+		d, err := time.ParseInLocation(dateFmt, s, time.Local)
+		if err != nil {
+			return f, "bad from", false
+		}
+		f.Since = d.UTC().Format(time.RFC3339)
+	}
+	if s := strings.TrimSpace(r.FormValue("to")); s != "" {
+		d, err := time.ParseInLocation(dateFmt, s, time.Local)
+		if err != nil {
+			return f, "bad to", false
+		}
+		f.Until = d.AddDate(0, 0, 1).UTC().Format(time.RFC3339)
+	}
+	if s := r.FormValue("limit"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			f.Limit = min(n, maxTasksLimit)
+		}
+	}
+	return f, "", true
+}
+
+// auditHandler — GET /api/admin/audit: журнал изменений задач.
+func (a *API) auditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	f, msg, ok := auditFilter(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	switch r.FormValue("format") {
+	case "", "json":
+	case "jsonl":
+		a.exportAudit(w, f)
+		return
+	default:
+		writeError(w, http.StatusBadRequest, "bad format")
+		return
+	}
+
+	entries, err := a.store.Audit(f)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db select error")
+		return
+	}
+	resp := auditResp{Entries: make([]auditItem, 0, len(entries))}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, auditItem{AuditEntry: e, Diff: json.RawMessage(e.Diff)})
+	}
+	writeJSON(w, resp)
+}
+
+// exportAudit выгружает все записи под фильтр f (limit не действует)
+// по одной JSON-строке, от старых к новым, читая журнал порциями.
+func (a *API) exportAudit(w http.ResponseWriter, f db.AuditFilter) {
+	f.Asc, f.Limit = true, maxTasksLimit
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=UTF-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	enc := json.NewEncoder(w)
+	for first := true; ; first = false {
+		entries, err := a.store.Audit(f)
+		if err != nil && first {
+			writeError(w, http.StatusInternalServerError, "db select error")
+			return
+		}
+		if err != nil {
+			// часть выгрузки уже отправлена — обрываем соединение
+			panic(http.ErrAbortHandler)
+		}
+		for _, e := range entries {
+			_ = enc.Encode(auditItem{AuditEntry: e, Diff: json.RawMessage(e.Diff)})
+		}
+		if len(entries) < f.Limit {
+			return
+		}
+		f.AfterID = entries[len(entries)-1].ID
+	}
+}
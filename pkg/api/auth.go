@@ -1,9 +1,13 @@
 // Package api: простая аутентификация по паролю из TODO_PASSWORD.
 // Реализован мини-JWT (HS256): подпись HMAC от header.payload с ключом = пароль.
-// Токен кладётся в cookie "token", срок — 8 часов. Middleware auth(...) проверяет токен.
+// Токен кладётся в cookie "token", срок — 8 часов. Middleware auth(...) проверяет токен
+// и кладёт в контекст запроса имя вошедшего (principal) для журнала изменений.
+// Именованные пользователи (TODO_USERS) входят со своим паролем, поэтому имя
+// в журнале подтверждено паролем; общий TODO_PASSWORD входит как "user".
 package api
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
@@ -18,12 +22,51 @@ var b64 = base64.RawURLEncoding
 
 const hexDigits = "0123456789abcdef"
 
-// password — кеш из переменной окружения. Пустая строка = аутентификация выключена.
-var password string
+// passwordFromEnv читает TODO_PASSWORD (вызываем из api.New()).
+// Пустая строка = аутентификация выключена.
+func passwordFromEnv() string {
+	return os.Getenv("TODO_PASSWORD")
+}
+
+// usersFromEnv читает именованных пользователей из TODO_USERS ("имя:пароль"
+// через запятую) и администраторов из TODO_ADMINS (имена через запятую).
+// Записи без имени или пароля и зарезервированные имена пропускаются.
+func (a *API) usersFromEnv() {
+	admins := map[string]bool{}
+	for _, name := range strings.Split(os.Getenv("TODO_ADMINS"), ",") {
+		admins[strings.TrimSpace(name)] = true
+	}
+	for _, entry := range strings.Split(os.Getenv("TODO_USERS"), ",") {
+		name, pass, ok := strings.Cut(entry, ":")
+		if ok {
+			a.SetUser(strings.TrimSpace(name), pass, admins[strings.TrimSpace(name)])
+		}
+	}
+}
+
+// SetUser добавляет (или меняет) пользователя name с паролем pass вместо
+// TODO_USERS; admin — доступ к /api/admin/... Пользователи работают только
+// при заданном пароле экземпляра (он же ключ подписи токенов); пустые
+// имя и пароль, имена "user" и "anonymous" и слишком длинные имена игнорируются.
+func (a *API) SetUser(name, pass string, admin bool) {
+	if name == "" || pass == "" || name == defaultPrincipal || name == anonymousPrincipal ||
+		len([]rune(name)) > maxPrincipalLen {
+		return
+	}
+	if a.users == nil {
+		a.users, a.admins = map[string]string{}, map[string]bool{}
+	}
+	a.users[name], a.admins[name] = pass, admin
+}
 
-// setPasswordFromEnv читает TODO_PASSWORD один раз (вызываем из api.Init()).
-func setPasswordFromEnv() {
-	password = os.Getenv("TODO_PASSWORD")
+// credential — пароль, которым подтверждается вход под именем sub
+// (пустое имя — общий пароль экземпляра).
+func (a *API) credential(sub string) (string, bool) {
+	if sub == "" {
+		return a.password, true
+	}
+	pass, ok := a.users[sub]
+	return pass, ok
 }
 
 // jwtHeader — заголовок токена (тип и алгоритм).
@@ -33,11 +76,32 @@ type jwtHeader struct {
 }
 
 // jwtPayload — полезная нагрузка токена.
-// Sum — hex(sha256(password)) для привязки токена к текущему паролю.
+// Sum — hex(sha256(password)) для привязки токена к текущему паролю
+// (общему или пароля пользователя Sub).
 // Exp — unix-время истечения (через 8 часов).
+// Sub — имя пользователя из TODO_USERS (пусто — вход по общему паролю).
 type jwtPayload struct {
 	Sum string `json:"sum"`
 	Exp int64  `json:"exp"`
+	Sub string `json:"sub,omitempty"`
+}
+
+// Имена для журнала изменений: вошедший без имени и запрос при выключенной аутентификации.
+const (
+	defaultPrincipal   = "user"
+	anonymousPrincipal = "anonymous"
+	maxPrincipalLen    = 64
+)
+
+// principalKey — ключ контекста запроса с именем вошедшего.
+type principalKey struct{}
+
+// principal возвращает имя вошедшего, от которого выполняется запрос.
+func principal(r *http.Request) string {
+	if p, ok := r.Context().Value(principalKey{}).(string); ok {
+		return p
+	}
+	return anonymousPrincipal
 }
 
 // sha256Hex возвращает hex-строку от sha256(input).
@@ -54,11 +118,13 @@ func sha256Hex(s string) string {
 }
 
 // makeJWT формирует токен: base64(header).base64(payload).base64(HMACSHA256(signing, password)).
-func makeJWT(pass string) (string, error) {
+// name попадает в токен как sub, cred — пароль, которым подтверждён вход.
+func makeJWT(pass, cred, name string) (string, error) {
 	h := jwtHeader{Alg: "HS256", Typ: "JWT"}
 	p := jwtPayload{
-		Sum: sha256Hex(pass),
+		Sum: sha256Hex(cred),
 		Exp: time.Now().Add(8 * time.Hour).Unix(),
+		Sub: name,
 	}
 	hb, _ := json.Marshal(h)
 	pb, _ := json.Marshal(p)
@@ -74,11 +140,14 @@ func makeJWT(pass string) (string, error) {
 	return signing + "." + ss, nil
 }
 
-// validateJWT проверяет подпись, срок действия и соответствие паролю.
-func validateJWT(token, pass string) bool {
+// validateJWT проверяет подпись, срок действия и соответствие паролю,
+// которым подтверждён вход под именем sub (credential), и возвращает
+// содержимое токена.
+func validateJWT(token, pass string, credential func(sub string) (string, bool)) (jwtPayload, bool) {
+	var p jwtPayload
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
-		return false
+		return p, false
 	}
 	signing := parts[0] + "." + parts[1]
 
@@ -89,66 +158,95 @@ func validateJWT(token, pass string) bool {
 
 	got, err := b64.DecodeString(parts[2])
 	if err != nil || !hmac.Equal(got, expect) {
-		return false
+		return p, false
 	}
 
 	// проверка payload
 	pb, err := b64.DecodeString(parts[1])
 	if err != nil {
-		return false
+		return p, false
 	}
-	var p jwtPayload
 	if err := json.Unmarshal(pb, &p); err != nil {
-		return false
+		return p, false
 	}
 	if time.Now().Unix() >= p.Exp {
-		return false
+		return p, false
 	}
-	if p.Sum != sha256Hex(pass) {
-		return false
+	if cred, ok := credential(p.Sub); !ok || p.Sum != sha256Hex(cred) {
+		return p, false
 	}
-	return true
+	return p, true
 }
 
 // auth — middleware для защиты маршрутов.
 // Если переменная окружения TODO_PASSWORD пуста, защита отключена.
-func auth(next http.HandlerFunc) http.HandlerFunc {
+func (a *API) auth(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if password == "" { // пароль не задан — защита выключена
+		if a.password == "" { // пароль не задан — защита выключена
 			next(w, r)
 			return
 		}
 		c, err := r.Cookie("token")
-		if err != nil || !validateJWT(c.Value, password) {
+		if err != nil {
 			http.Error(w, "Authentication required", http.StatusUnauthorized)
 			return
 		}
+		p, ok := validateJWT(c.Value, a.password, a.credential)
+		if !ok {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		if p.Sub == "" {
+			p.Sub = defaultPrincipal
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p.Sub)))
+	})
+}
+
+// admin — middleware для маршрутов /api/admin/...: пускает только пользователей
+// с правом администратора (TODO_ADMINS); вход по общему паролю его не даёт.
+// При выключенной аутентификации открыто всё. Вызывается внутри auth(...).
+func (a *API) admin(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.password != "" && !a.admins[principal(r)] {
+			writeError(w, http.StatusForbidden, "admin required")
+			return
+		}
 		next(w, r)
 	})
 }
 
 // signinHandler — обработчик POST /api/signin.
-// Принимает JSON {"password": "..."} и возвращает {"token": "..."} при успехе.
-func signinHandler(w http.ResponseWriter, r *http.Request) {
+// Принимает JSON {"password": "...", "name": "..."} и возвращает {"token": "..."} при успехе.
+// Без name проверяется общий пароль, с name — пароль пользователя из TODO_USERS;
+// это имя попадёт в журнал изменений.
+func (a *API) signinHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 	var in struct {
 		Password string `json:"password"`
+		Name     string `json:"name"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		writeError(w, http.StatusBadRequest, "json parse error")
 		return
 	}
-	if password == "" {
+	if a.password == "" {
 		writeError(w, http.StatusBadRequest, "auth disabled")
 		return
 	}
-	if in.Password != password {
+	name := strings.TrimSpace(in.Name)
+	if len([]rune(name)) > maxPrincipalLen {
+		writeError(w, http.StatusBadRequest, "name too long")
+		return
+	}
+	cred, ok := a.credential(name)
+	if !ok || in.Password != cred {
 		writeError(w, http.StatusUnauthorized, "invalid password")
 		return
 	}
-	tok, _ := makeJWT(password)
+	tok, _ := makeJWT(a.password, cred, name)
 	writeJSON(w, map[string]string{"token": tok})
 }
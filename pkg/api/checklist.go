@@ -0,0 +1,72 @@
+// Package api: чек-листы задач.
+// POST /api/task/checklist?id=...&item=...[&done=false] — отметить пункт
+// выполненным (done=false — снять отметку).
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"todo/pkg/db"
+)
+
+// Ограничения чек-листа: число пунктов и длина пункта в символах.
+const (
+	maxChecklistItems = 100
+	maxItemLen        = 255
+)
+
+// checkChecklist проверяет пункты чек-листа задачи (пробелы по краям убираются).
+func checkChecklist(tk *db.Task) error {
+	if len(tk.Checklist) > maxChecklistItems {
+		return errors.New("too many checklist items")
+	}
+	for i := range tk.Checklist {
+		it := &tk.Checklist[i]
+		it.Title = strings.TrimSpace(it.Title)
+		if it.Title == "" {
+			return errors.New("empty checklist item")
+		}
+		if len([]rune(it.Title)) > maxItemLen {
+			return errors.New("checklist item too long")
+		}
+	}
+	return nil
+}
+
+// checklistHandler — POST /api/task/checklist: отметка одного пункта.
+func (a *API) checklistHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "no id")
+		return
+	}
+	item, err := strconv.ParseInt(r.URL.Query().Get("item"), 10, 64)
+	if err != nil || item <= 0 {
+		writeError(w, http.StatusBadRequest, "bad item")
+		return
+	}
+	done := true
+	if s := r.URL.Query().Get("done"); s != "" {
+		if done, err = strconv.ParseBool(s); err != nil {
+			writeError(w, http.StatusBadRequest, "bad done")
+			return
+		}
+	}
+	before, err := a.store.GetTask(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+	if err := a.store.CheckItem(id, item, done); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	a.audited(w, r, auditCheck, before.ID, before)
+}
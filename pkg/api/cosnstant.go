@@ -5,3 +5,6 @@ package api
 // Используется при парсинге/форматировании дат в API.
 const dateFmt = "20060102"
 const defaultTasksLimit = 50
+
+// maxTasksLimit — наибольший limit для /api/tasks; дальше — по курсору.
+const maxTasksLimit = 500
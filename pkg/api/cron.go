@@ -0,0 +1,164 @@
+// Package api: режим повторения по cron-выражению ("cron 0 9 1,15 * *").
+// Планировщик работает с датами, поэтому поля минут и часов только проверяются,
+// а дата выбирается по полям "день месяца", "месяц" и "день недели".
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros — сокращения, принятые в большинстве реализаций cron.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+}
+
+// cronMonths и cronDays — символьные имена месяцев и дней недели.
+var cronMonths = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var cronDays = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+// cronField — множество допустимых значений одного поля.
+// any == true, если поле было задано как "*" (важно для дня месяца/недели).
+type cronField struct {
+	set []bool
+	any bool
+}
+
+// cronExpr — разобранное пятипольное выражение.
+type cronExpr struct {
+	dom   cronField
+	month cronField
+	dow   cronField
+}
+
+// parseCron разбирает выражение "мин час день_месяца месяц день_недели"
+// (или макрос вида @monthly). Поддержаны "*", списки, диапазоны и шаги.
+func parseCron(args []string) (*cronExpr, error) {
+	if len(args) == 1 {
+		if m, ok := cronMacros[strings.ToLower(args[0])]; ok {
+			args = strings.Fields(m)
+		}
+	}
+	if len(args) != 5 {
+		return nil, errors.New("bad cron format")
+	}
+	if _, err := parseCronField(args[0], 0, 59, nil); err != nil {
+		return nil, errors.New("bad cron minute")
+	}
+	if _, err := parseCronField(args[1], 0, 23, nil); err != nil {
+		return nil, errors.New("bad cron hour")
+	}
+	dom, err := parseCronField(args[2], 1, 31, nil)
+	if err != nil {
+		return nil, errors.New("bad cron day of month")
+	}
+	month, err := parseCronField(args[3], 1, 12, cronMonths)
+	if err != nil {
+		return nil, errors.New("bad cron month")
+	}
+	// 7 — тоже воскресенье, как в большинстве реализаций
+	dow, err := parseCronField(args[4], 0, 7, cronDays)
+	if err != nil {
+		return nil, errors.New("bad cron day of week")
+	}
+	if dow.set[7] {
+		dow.set[0] = true
+	}
+	return &cronExpr{dom: dom, month: month, dow: dow}, nil
+}
+
+// parseCronField разбирает одно поле: "*", "*/N", "A", "A-B", "A-B/N", "A/N"
+// и их списки через запятую. names — необязательные символьные имена значений.
+func parseCronField(s string, lo, hi int, names map[string]int) (cronField, error) {
+	f := cronField{set: make([]bool, hi+1), any: s == "*"}
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return f, errors.New("bad step")
+			}
+			step = n
+		}
+
+		from, to := lo, hi
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if from, err = cronValue(a, names); err != nil {
+				return f, err
+			}
+			if to, err = cronValue(b, names); err != nil {
+				return f, err
+			}
+		default:
+			v, err := cronValue(rng, names)
+			if err != nil {
+				return f, err
+			}
+			from = v
+			if !hasStep {
+				to = v // "A/N" означает "от A до конца с шагом N"
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return f, errors.New("value out of range")
+		}
+		for v := from; v <= to; v += step {
+			f.set[v] = true
+		}
+	}
+	return f, nil
+}
+
+// cronValue — число или символьное имя (JAN, MON, ...).
+func cronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// match проверяет дату по правилам cron: если ограничены и день месяца,
+// и день недели, достаточно совпадения любого из них.
+func (c *cronExpr) match(d time.Time) bool {
+	if !c.month.set[d.Month()] {
+		return false
+	}
+	domOK := c.dom.set[d.Day()]
+	dowOK := c.dow.set[d.Weekday()]
+	switch {
+	case c.dom.any && c.dow.any:
+		return true
+	case c.dom.any:
+		return dowOK
+	case c.dow.any:
+		return domOK
+	default:
+		return domOK || dowOK
+	}
+}
+
+// nextCron — правило "cron <выражение>": ближайший подходящий день после now и start.
+func nextCron(now, start time.Time, args []string) (string, error) {
+	c, err := parseCron(args)
+	if err != nil {
+		return "", err
+	}
+	return scanDays(now, start, c.match)
+}
@@ -0,0 +1,89 @@
+// Package api: даты-исключения (EXDATE) повторяющихся задач.
+// POST/DELETE /api/task/exdate?id=...&date=20060102
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"todo/pkg/db"
+)
+
+// skipExdate возвращает ближайшую дату серии после t.Date, не попавшую в исключения,
+// и её дату по расписанию (как nextDates). Ограничения серии (repeat_until,
+// repeat_count) действуют как при выполнении: если t.Date — последнее
+// повторение, возвращается errNoOccurrences.
+func skipExdate(t *db.Task) (string, string, error) {
+	from, err := time.Parse(dateFmt, t.Date)
+	if err != nil {
+		return "", "", err
+	}
+	return nextOccurrence(from, t)
+}
+
+// exdateHandler — добавление (POST) и удаление (DELETE) даты-исключения.
+// Если исключается текущая дата задачи, задача сразу переносится
+// на следующее повторение серии; исключить последнее повторение нельзя
+// (задачу с ним остаётся выполнить или удалить).
+func (a *API) exdateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "no id")
+		return
+	}
+	date := strings.TrimSpace(r.URL.Query().Get("date"))
+	if _, err := time.Parse(dateFmt, date); err != nil {
+		writeError(w, http.StatusBadRequest, "bad date format")
+		return
+	}
+	t, err := a.store.GetTask(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+
+	before := cloneTask(t)
+
+	if r.Method == http.MethodDelete {
+		if err := a.store.DeleteExdate(id, date); err != nil {
+			writeError(w, http.StatusNotFound, "exdate not found")
+			return
+		}
+		a.audited(w, r, auditExdateDelete, t.ID, before)
+		return
+	}
+
+	if strings.TrimSpace(t.Repeat) == "" {
+		writeError(w, http.StatusBadRequest, "exdates without repeat")
+		return
+	}
+	if date != t.Date {
+		if err := a.store.AddExdate(id, date); err != nil {
+			writeError(w, http.StatusInternalServerError, "db insert error")
+			return
+		}
+		a.audited(w, r, auditExdateAdd, t.ID, before)
+		return
+	}
+	t.Exdates = append(t.Exdates, date)
+	next, scheduled, err := skipExdate(t)
+	if errors.Is(err, errNoOccurrences) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad repeat")
+		return
+	}
+	if err := a.store.SkipOccurrence(date, next, scheduled, id); err != nil {
+		writeError(w, http.StatusNotFound, "update error")
+		return
+	}
+	a.audited(w, r, auditExdateAdd, t.ID, before)
+}
@@ -0,0 +1,72 @@
+// Package api: история выполнения задач.
+// GET /api/history[?task_id=...][&from=20060102][&to=20060102][&limit=N]
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo/pkg/db"
+)
+
+// defaultHistoryLimit — сколько записей истории отдавать по умолчанию.
+const defaultHistoryLimit = 100
+
+// historyResp — форма ответа: {"history":[...]}, сначала последние выполнения.
+type historyResp struct {
+	History []*db.Completion `json:"history"`
+}
+
+// historyHandler — GET /api/history. task_id — только выполнения этой задачи,
+// from и to — период по дню выполнения (включительно, по местному времени),
+// limit — не больше maxTasksLimit записей.
+func (a *API) historyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	f := db.HistoryFilter{Limit: defaultHistoryLimit}
+	if s := strings.TrimSpace(r.FormValue("task_id")); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "bad task_id")
+			return
+		}
+		f.TaskID = n
+	}
+	// границы дней переводим в UTC — в нём хранится время выполнения
+	if s := strings.TrimSpace(r.FormValue("from")); s != "" {
+		d, err := time.ParseInLocation(dateFmt, s, time.Local)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad from")
+			return
+		}
+		f.Since = d.UTC().Format(time.RFC3339)
+	}
+	if s := strings.TrimSpace(r.FormValue("to")); s != "" {
+		d, err := time.ParseInLocation(dateFmt, s, time.Local)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad to")
+			return
+		}
+		f.Until = d.AddDate(0, 0, 1).UTC().Format(time.RFC3339)
+	}
+	if f.Since != "" && f.Until != "" && f.Since >= f.Until {
+		writeError(w, http.StatusBadRequest, "from is after to")
+		return
+	}
+	if s := r.FormValue("limit"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			f.Limit = min(n, maxTasksLimit)
+		}
+	}
+
+	items, err := a.store.History(f)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db select error")
+		return
+	}
+	writeJSON(w, historyResp{History: items})
+}
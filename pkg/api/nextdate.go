@@ -3,14 +3,23 @@
 package api
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"todo/pkg/db"
 )
 
+// errNoOccurrences — серия повторений исчерпана (COUNT/UNTIL правила
+// или ограничения задачи repeat_until/repeat_count).
+var errNoOccurrences = errors.New("no further occurrences")
+
 // afterNow возвращает true, если дата d строго больше now (сравнение по дню).
 func afterNow(d, now time.Time) bool {
 	yd, md, dd := d.Date()
@@ -21,7 +30,18 @@ func afterNow(d, now time.Time) bool {
 }
 
 // NextDate — базовая логика повторений.
-// Поддержаны правила: "y" (ежегодно), "d N" (через N дней, 1..400).
+// Поддержаны правила:
+//   - "y"             — ежегодно;
+//   - "d N"           — через N дней (1..400);
+//   - "w D[,D...]"    — по дням недели D (1 — понедельник, ..., 7 — воскресенье);
+//   - "m D[,D...] [M[,M...]]" — по дням месяца D (1..31, -1 — последний,
+//     -2 — предпоследний), опционально только в месяцах M (1..12);
+//   - "cron <выражение>" — пятипольное cron-выражение, см. cron.go;
+//   - "RRULE:..."     — правило iCalendar (RFC 5545), см. rrule.go;
+//   - "b N [страна]"  — через N рабочих дней с учётом праздников, см. workday.go;
+//   - "<правило> | next|prev [страна]" — перенос даты с выходного/праздника
+//     на ближайший рабочий день вперёд или назад.
+//
 // Остальные форматы пока считаются неподдерживаемыми (ошибка).
 func NextDate(now time.Time, dstart string, repeat string) (string, error) {
 	if strings.TrimSpace(repeat) == "" {
@@ -31,6 +51,13 @@ func NextDate(now time.Time, dstart string, repeat string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if base, mod, ok := strings.Cut(repeat, "|"); ok {
+		next, _, err := nextShifted(now, dstart, strings.TrimSpace(base), mod)
+		return next, err
+	}
+	if isRRule(repeat) {
+		return nextRRule(now, start, repeat)
+	}
 
 	parts := strings.Fields(repeat)
 	switch parts[0] {
@@ -60,21 +87,230 @@ func NextDate(now time.Time, dstart string, repeat string) (string, error) {
 				return d.Format(dateFmt), nil
 			}
 		}
+	case "w":
+		return nextWeekly(now, start, parts[1:])
+	case "m":
+		return nextMonthly(now, start, parts[1:])
+	case "cron":
+		return nextCron(now, start, parts[1:])
+	case "b":
+		return nextWorkdays(now, start, parts[1:])
 	default:
 		return "", errors.New("unsupported repeat")
 	}
 }
 
-// nextDateHandler — GET /api/nextdate?now=20060102&date=20060102&repeat=...
-// Возвращает дату следующего выполнения (строкой) или текст "error".
+// maxScanDays — горизонт поиска для правил m/w (около 10 лет).
+// Нужен, чтобы правила вроде "m 31 2" не приводили к бесконечному циклу.
+const maxScanDays = 3660
+
+// scanDays перебирает дни, начиная со следующего после max(now, start),
+// и возвращает первый, для которого match вернул true.
+func scanDays(now, start time.Time, match func(time.Time) bool) (string, error) {
+	d := start
+	if afterNow(now, d) {
+		d = now
+	}
+	for i := 0; i < maxScanDays; i++ {
+		d = d.AddDate(0, 0, 1)
+		if match(d) {
+			return d.Format(dateFmt), nil
+		}
+	}
+	return "", errors.New("no matching date")
+}
+
+// parseInts разбирает список чисел через запятую ("1,5,-1").
+func parseInts(s string) ([]int, error) {
+	var out []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// nextWeekly — правило "w <дни недели>", где 1 — понедельник, 7 — воскресенье.
+func nextWeekly(now, start time.Time, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("bad w format")
+	}
+	days, err := parseInts(args[0])
+	if err != nil {
+		return "", errors.New("bad w day")
+	}
+	var week [8]bool
+	for _, n := range days {
+		if n < 1 || n > 7 {
+			return "", errors.New("bad w day")
+		}
+		week[n] = true
+	}
+
+	return scanDays(now, start, func(d time.Time) bool {
+		wd := int(d.Weekday())
+		if wd == 0 { // time.Sunday = 0 → 7
+			wd = 7
+		}
+		return week[wd]
+	})
+}
+
+// nextMonthly — правило "m <дни> [месяцы]".
+// Дни: 1..31, -1 (последний день месяца), -2 (предпоследний).
+// Если в месяце нет указанного дня (например, 31 в апреле) — месяц пропускается.
+func nextMonthly(now, start time.Time, args []string) (string, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return "", errors.New("bad m format")
+	}
+	days, err := parseInts(args[0])
+	if err != nil {
+		return "", errors.New("bad m day")
+	}
+	for _, n := range days {
+		if (n < 1 || n > 31) && n != -1 && n != -2 {
+			return "", errors.New("bad m day")
+		}
+	}
+
+	var months [13]bool
+	if len(args) == 2 {
+		ms, err := parseInts(args[1])
+		if err != nil {
+			return "", errors.New("bad m month")
+		}
+		for _, n := range ms {
+			if n < 1 || n > 12 {
+				return "", errors.New("bad m month")
+			}
+			months[n] = true
+		}
+	} else {
+		for i := 1; i <= 12; i++ {
+			months[i] = true
+		}
+	}
+
+	return scanDays(now, start, func(d time.Time) bool {
+		if !months[d.Month()] {
+			return false
+		}
+		// последний день месяца = нулевой день следующего
+		last := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.Local).Day()
+		for _, n := range days {
+			switch {
+			case n > 0 && d.Day() == n:
+				return true
+			case n < 0 && d.Day() == last+n+1:
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// nextDates — NextDate для задачи t. Для правил с переносом на рабочий день
+// серия отсчитывается от даты по расписанию t.Scheduled (если дата задачи уже
+// перенесена), и вторым значением возвращается дата по расписанию найденного
+// повторения, если из-за переноса она другая (иначе — пусто).
+func nextDates(now time.Time, t *db.Task) (string, string, error) {
+	base, mod, shifted := strings.Cut(t.Repeat, "|")
+	if !shifted {
+		next, err := NextDate(now, t.Date, t.Repeat)
+		return next, "", err
+	}
+	start := cmp.Or(t.Scheduled, t.Date)
+	if _, err := time.Parse(dateFmt, start); err != nil {
+		return "", "", err
+	}
+	// перенос мог совпасть с текущей датой задачи или уйти раньше неё —
+	// тогда берём следующую дату правила
+	for range maxScanDays {
+		next, scheduled, err := nextShifted(now, start, strings.TrimSpace(base), mod)
+		if err != nil || next > t.Date {
+			return next, scheduled, err
+		}
+		start = cmp.Or(scheduled, next)
+	}
+	return "", "", errors.New("no matching date")
+}
+
+// nextDateSkipping — nextDates, пропускающий даты-исключения t.Exdates.
+// Каждое совпадение с исключением уникально, поэтому итераций не больше len(Exdates)+1.
+func nextDateSkipping(now time.Time, t *db.Task) (string, string, error) {
+	for range len(t.Exdates) + 1 {
+		next, scheduled, err := nextDates(now, t)
+		if err != nil {
+			return "", "", err
+		}
+		if !slices.Contains(t.Exdates, next) {
+			return next, scheduled, nil
+		}
+		now, _ = time.Parse(dateFmt, next)
+	}
+	return "", "", errNoOccurrences
+}
+
+// nextOccurrence — NextDate с учётом исключений и ограничений серии задачи:
+// t.Date считается повторением номер RepeatDone+1, всего допускается
+// RepeatCount повторений (и не больше COUNT правила RRULE), и ни одно
+// не может быть позже RepeatUntil.
+// Вторым значением возвращается дата по расписанию, как у nextDates.
+func nextOccurrence(now time.Time, t *db.Task) (string, string, error) {
+	limit := t.RepeatCount
+	if c := rruleCount(t.Repeat); c > 0 && (limit == 0 || c < limit) {
+		limit = c
+	}
+	if limit > 0 && t.RepeatDone+1 >= limit {
+		return "", "", errNoOccurrences
+	}
+	next, scheduled, err := nextDateSkipping(now, t)
+	if err != nil {
+		return "", "", err
+	}
+	if t.RepeatUntil != "" && next > t.RepeatUntil {
+		return "", "", errNoOccurrences
+	}
+	return next, scheduled, nil
+}
+
+// nextDateHandler — GET /api/nextdate?now=20060102&date=20060102&repeat=...[&until=20060102][&count=N][&exdates=...]
+// Возвращает дату следующего выполнения (строкой) или JSON с ошибкой.
+// until и count — необязательные ограничения серии (count считает и саму date).
 func nextDateHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	now, t, err := seriesParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	next, _, err := nextOccurrence(now, t)
+	if err != nil {
+		// корректная обработка: 400 + JSON с ошибкой
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fmt.Fprintln(w, next)
+}
+
+// seriesParams разбирает общие параметры запросов о серии повторений:
+// now (по умолчанию — сегодня), date, repeat, until, count и exdates
+// (даты-исключения через запятую).
+func seriesParams(r *http.Request) (time.Time, *db.Task, error) {
 	nowStr := strings.TrimSpace(r.FormValue("now"))
 	dateStr := strings.TrimSpace(r.FormValue("date"))
 	repeat := strings.TrimSpace(r.FormValue("repeat"))
+	if repeat == "" {
+		// RRULE с неэкранированными ";" стандартный разбор query отбрасывает
+		repeat = strings.TrimSpace(rawQueryValue(r.URL.RawQuery, "repeat"))
+	}
 
 	var now time.Time
 	var err error
@@ -85,18 +321,39 @@ func nextDateHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		now, err = time.Parse(dateFmt, nowStr)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, "bad now")
-			return
+			return now, nil, errors.New("bad now")
 		}
 	}
 
-	next, err := NextDate(now, dateStr, repeat)
-	if err != nil {
-		// корректная обработка: 400 + JSON с ошибкой
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-		w.WriteHeader(http.StatusBadRequest)
-		writeJSON(w, map[string]string{"error": err.Error()})
-		return
+	t := &db.Task{Date: dateStr, Repeat: repeat}
+	t.RepeatUntil = strings.TrimSpace(r.FormValue("until"))
+	if s := strings.TrimSpace(r.FormValue("count")); s != "" {
+		if t.RepeatCount, err = strconv.ParseInt(s, 10, 64); err != nil || t.RepeatCount < 0 {
+			return now, nil, errors.New("bad count")
+		}
 	}
-	fmt.Fprintln(w, next)
+	if s := strings.TrimSpace(r.FormValue("exdates")); s != "" {
+		for _, d := range strings.Split(s, ",") {
+			if _, err := time.Parse(dateFmt, d); err != nil {
+				return now, nil, errors.New("bad exdate")
+			}
+			t.Exdates = append(t.Exdates, d)
+		}
+	}
+	return now, t, nil
+}
+
+// rawQueryValue достаёт значение параметра key из "сырой" строки запроса,
+// разделяя пары только по "&" (точка с запятой остаётся частью значения).
+func rawQueryValue(rawQuery, key string) string {
+	for _, pair := range strings.Split(rawQuery, "&") {
+		k, v, _ := strings.Cut(pair, "=")
+		if k != key {
+			continue
+		}
+		if s, err := url.QueryUnescape(v); err == nil {
+			return s
+		}
+	}
+	return ""
 }
@@ -0,0 +1,91 @@
+// Package api: предпросмотр ближайших дат серии повторений.
+// GET /api/occurrences?date=...&repeat=...[&now=...][&n=5][&to=...][&until=...][&count=...]
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo/pkg/db"
+)
+
+// defaultOccurrences и maxOccurrences — сколько дат возвращать по умолчанию и максимум.
+const (
+	defaultOccurrences = 5
+	maxOccurrences     = 100
+)
+
+// occurrencesResp — форма ответа: {"dates":["20240201", ...]}.
+type occurrencesResp struct {
+	Dates []string `json:"dates"`
+}
+
+// Occurrences возвращает до n ближайших дат серии t после now, не позже to
+// (нулевое to — без ограничения окна). Используется тот же движок, что и в NextDate:
+// дата задачи остаётся началом серии, а "сейчас" сдвигается на найденную дату.
+func Occurrences(now time.Time, t *db.Task, n int, to time.Time) ([]string, error) {
+	series := *t
+	out := make([]string, 0, n)
+	for len(out) < n {
+		next, _, err := nextOccurrence(now, &series)
+		if errors.Is(err, errNoOccurrences) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !to.IsZero() && next > to.Format(dateFmt) {
+			break
+		}
+		out = append(out, next)
+
+		now, _ = time.Parse(dateFmt, next)
+		series.RepeatDone++
+	}
+	return out, nil
+}
+
+// occurrencesHandler — GET /api/occurrences.
+// Параметры date, repeat, now, until, count — как у /api/nextdate;
+// n — сколько дат вернуть (1..100, по умолчанию 5), to — конец окна (20060102).
+func occurrencesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	now, t, err := seriesParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	n := defaultOccurrences
+	if s := strings.TrimSpace(r.FormValue("n")); s != "" {
+		n, err = strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxOccurrences {
+			writeError(w, http.StatusBadRequest, "bad n")
+			return
+		}
+	}
+	var to time.Time
+	if s := strings.TrimSpace(r.FormValue("to")); s != "" {
+		if to, err = time.Parse(dateFmt, s); err != nil {
+			writeError(w, http.StatusBadRequest, "bad to")
+			return
+		}
+		// окно задано явно — перечисляем до его конца, но не больше maxOccurrences
+		if r.FormValue("n") == "" {
+			n = maxOccurrences
+		}
+	}
+
+	dates, err := Occurrences(now, t, n, to)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, occurrencesResp{Dates: dates})
+}
@@ -0,0 +1,427 @@
+// Package api: разбор правил повторения, записанных обычным языком
+// ("every other Friday", "каждый понедельник", "last day of month")
+// в канонические строки repeat, понятные NextDate.
+// GET /api/repeat/parse?text=...
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// phraseWeekdays — префиксы русских названий дней недели → 1..7.
+// Порядок важен: "четверг" проверяется раньше порядкового "четвёртый",
+// а "вторник" — раньше "второй".
+var phraseWeekdays = []struct {
+	prefix string
+	day    int
+}{
+	{"понедельн", 1}, {"вторник", 2}, {"сред", 3}, {"четверг", 4},
+	{"пятниц", 5}, {"суббот", 6}, {"воскресен", 7},
+}
+
+// phraseWeekdaysEn — английские названия дней недели (индекс 1..7); подходят
+// целиком, во множественном числе или сокращённые не короче трёх букв.
+var phraseWeekdaysEn = [...]string{"", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+// phraseOrdinals — порядковые слова: first..fifth, last, предпоследний.
+var phraseOrdinals = []struct {
+	prefix string
+	n      int
+}{
+	{"перв", 1}, {"втор", 2}, {"трет", 3}, {"четверт", 4}, {"пят", 5},
+	{"предпоследн", -2}, {"последн", -1},
+}
+
+// phraseOrdinalsEn — английские порядковые слова (только целиком).
+var phraseOrdinalsEn = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "last": -1,
+}
+
+// phraseNumbers — количественные числительные ("every three days", "каждые две недели").
+var phraseNumbers = map[string]int{
+	"one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+	"один": 1, "одну": 1, "два": 2, "две": 2, "три": 3, "четыре": 4, "пять": 5,
+	"шесть": 6, "семь": 7, "восемь": 8, "девять": 9, "десять": 10,
+}
+
+// phraseUnits — единицы интервала: d (день), w (неделя), m (месяц), y (год).
+// Английские слова сравниваются целиком, русские — по префиксу.
+var phraseUnits = []struct {
+	prefix string
+	unit   string
+}{
+	{"ежедневн", "d"}, {"ден", "d"}, {"дня", "d"}, {"дне", "d"},
+	{"еженедельн", "w"}, {"недел", "w"},
+	{"ежемесячн", "m"}, {"месяц", "m"},
+	{"ежегодн", "y"}, {"год", "y"}, {"лет", "y"},
+}
+
+// phraseUnitsEn — английские единицы интервала.
+var phraseUnitsEn = map[string]string{
+	"day": "d", "days": "d", "daily": "d",
+	"week": "w", "weeks": "w", "weekly": "w",
+	"month": "m", "months": "m", "monthly": "m",
+	"year": "y", "years": "y", "yearly": "y", "annually": "y",
+}
+
+// phraseFiller — служебные слова, которые не меняют правило.
+var phraseFiller = map[string]bool{
+	"every": true, "each": true, "on": true, "of": true, "the": true, "and": true,
+	"in": true, "a": true, "once": true,
+	"каждый": true, "каждая": true, "каждое": true, "каждые": true, "каждую": true,
+	"каждого": true, "каждой": true, "каждом": true, "каждых": true,
+	"по": true, "в": true, "во": true, "и": true, "числа": true, "число": true, "раз": true,
+}
+
+// rruleDayCodes — коды дней недели для BYDAY (индекс 1..7).
+var rruleDayCodes = [...]string{"", "MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// phrase — признаки, собранные из фразы.
+type phrase struct {
+	n          int      // интервал ("every 3 days", "every other" → 2)
+	unit       string   // d, w, m, y или ""
+	weekdays   []int    // дни недели 1..7
+	except     []int    // дни недели после "except"/"кроме"
+	monthDays  []int    // дни месяца 1..31, -1, -2
+	byDay      []string // BYDAY с номерами: 1MO, -1FR
+	working    bool     // рабочие дни с учётом праздников
+	dayOrdinal int      // "second day", "2nd day": день месяца или интервал — решает canonical
+}
+
+// weekdayOf, ordinalOf, unitOf — поиск слова в соответствующих таблицах.
+func weekdayOf(w string) (int, bool) {
+	for day, name := range phraseWeekdaysEn {
+		if day > 0 && (w == name+"s" || len(w) >= 3 && strings.HasPrefix(name, w)) {
+			return day, true
+		}
+	}
+	for _, e := range phraseWeekdays {
+		if strings.HasPrefix(w, e.prefix) {
+			return e.day, true
+		}
+	}
+	return 0, false
+}
+
+func ordinalOf(w string) (int, bool) {
+	if n, ok := phraseOrdinalsEn[w]; ok {
+		return n, true
+	}
+	for _, e := range phraseOrdinals {
+		if strings.HasPrefix(w, e.prefix) {
+			return e.n, true
+		}
+	}
+	return 0, false
+}
+
+func unitOf(w string) (string, bool) {
+	if u, ok := phraseUnitsEn[w]; ok {
+		return u, true
+	}
+	for _, e := range phraseUnits {
+		if strings.HasPrefix(w, e.prefix) {
+			return e.unit, true
+		}
+	}
+	return "", false
+}
+
+// isWorking — "working/business day", "рабочий день".
+func isWorking(w string) bool {
+	return w == "working" || w == "business" || strings.HasPrefix(w, "рабоч")
+}
+
+// isExcept — "except", "кроме": следующие дни недели исключаются.
+func isExcept(w string) bool {
+	return w == "except" || w == "кроме"
+}
+
+// phraseNumber разбирает число, в том числе "15th", "2nd", "15-го", "3-е",
+// и количественные числительные ("three", "две").
+// suffix == true, если у числа был порядковый суффикс.
+func phraseNumber(w string) (n int, suffix bool, ok bool) {
+	if n, ok := phraseNumbers[w]; ok {
+		return n, false, true
+	}
+	num := strings.TrimRight(w, "stndrhгоеяй-")
+	if num == "" {
+		return 0, false, false
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil {
+		return 0, false, false
+	}
+	return n, num != w, true
+}
+
+// ParseRepeatPhrase переводит фразу на русском или английском в каноническое
+// правило repeat. Примеры:
+//
+//	"every day", "ежедневно"                → "d 1"
+//	"every 3 days", "каждые две недели"     → "d 3" / "d 14"
+//	"каждый понедельник", "on Mon, Thu"     → "w 1" / "w 1,4"
+//	"every other Friday"                    → "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=FR"
+//	"every 2 weeks on Mon, Thu"             → "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"
+//	"weekdays", "по будням"                 → "w 1,2,3,4,5"
+//	"every weekday except friday"           → "w 1,2,3,4"
+//	"every working day", "каждый 3-й рабочий день" → "b 1" / "b 3"
+//	"last day of month", "15 числа"         → "m -1" / "m 15"
+//	"second Tuesday of month"               → "RRULE:FREQ=MONTHLY;BYDAY=2TU"
+//	"every year", "ежегодно"                → "y"
+//
+// Незнакомое слово — ошибка: лучше отказать, чем молча построить не то правило.
+func ParseRepeatPhrase(text string) (string, error) {
+	text = strings.ToLower(strings.ReplaceAll(text, "ё", "е"))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '.' || r == ';' || r == '\t'
+	})
+	if len(words) == 0 {
+		return "", errors.New("empty phrase")
+	}
+
+	p := phrase{n: 1}
+	ordinal := 0    // порядковое слово, ждущее "день" или день недели
+	except := false // после "except" идут только исключаемые дни недели
+	for i, w := range words {
+		next := ""
+		if i+1 < len(words) {
+			next = words[i+1]
+		}
+		if except {
+			d, ok := weekdayOf(w)
+			switch {
+			case ok:
+				p.except = append(p.except, d)
+			case w != "and" && w != "и":
+				return "", fmt.Errorf("unexpected word %q after except", w)
+			}
+			continue
+		}
+		if ordinal != 0 {
+			// порядковое слово относится только к дню недели, "рабочему" или "дню"
+			_, weekday := weekdayOf(w)
+			if u, _ := unitOf(w); !weekday && !isWorking(w) && u != "d" {
+				return "", fmt.Errorf("unexpected word %q after ordinal", w)
+			}
+		}
+		switch {
+		case w == "other" || w == "через":
+			p.n = 2
+		case w == "weekday" || w == "weekdays" || strings.HasPrefix(w, "будн"):
+			p.weekdays = append(p.weekdays, 1, 2, 3, 4, 5)
+		case w == "weekend" || w == "weekends" || strings.HasPrefix(w, "выходн"):
+			p.weekdays = append(p.weekdays, 6, 7)
+		case isExcept(w):
+			except = true
+		case isWorking(w):
+			p.working = true
+			if ordinal != 0 {
+				// "third working day" — каждый третий рабочий день
+				p.n, ordinal = ordinal, 0
+			}
+		case phraseFiller[w]:
+			if (w == "раз" || w == "once") && i > 0 {
+				if _, _, ok := phraseNumber(words[i-1]); ok {
+					return "", fmt.Errorf("unsupported frequency %q", words[i-1]+" "+w)
+				}
+			}
+		default:
+			if n, suffix, ok := phraseNumber(w); ok {
+				_, unitNext := unitOf(next)
+				_, weekdayNext := weekdayOf(next)
+				switch {
+				case suffix && (weekdayNext || isWorking(next) || unitNext):
+					// "2nd Tuesday", "3rd working day", "2nd day" — как порядковое слово
+					ordinal = n
+				case !suffix && (unitNext || isWorking(next)):
+					// число перед единицей — интервал ("3 days")
+					p.n = n
+				default:
+					// иначе — день месяца ("15th", "1 и 15 числа")
+					p.monthDays = append(p.monthDays, n)
+				}
+				continue
+			}
+			if u, ok := unitOf(w); ok {
+				if u == "d" && ordinal != 0 {
+					// "last day of month", "second day" — решается в canonical
+					p.dayOrdinal, ordinal = ordinal, 0
+				}
+				if u == "d" && p.working {
+					continue
+				}
+				// в "15 числа каждого месяца" и "day of month" главная единица — месяц
+				if p.unit == "" || u == "m" || u == "y" {
+					p.unit = u
+				}
+				continue
+			}
+			if d, ok := weekdayOf(w); ok {
+				if ordinal != 0 {
+					p.byDay = append(p.byDay, fmt.Sprintf("%d%s", ordinal, rruleDayCodes[d]))
+					ordinal = 0
+				} else {
+					p.weekdays = append(p.weekdays, d)
+				}
+				continue
+			}
+			if n, ok := ordinalOf(w); ok {
+				ordinal = n
+				continue
+			}
+			return "", fmt.Errorf("unknown word %q", w)
+		}
+	}
+	if ordinal != 0 {
+		return "", errors.New("ordinal without day")
+	}
+	if except && len(p.except) == 0 {
+		return "", errors.New("nothing after except")
+	}
+	return p.canonical()
+}
+
+// canonical собирает строку repeat из признаков фразы. Несовместимые признаки
+// ("15 числа по понедельникам", "every 3 days on Monday") — ошибка.
+func (p phrase) canonical() (string, error) {
+	if p.dayOrdinal != 0 {
+		if p.unit == "m" || p.dayOrdinal < 0 {
+			// "last day of month", "второй день месяца"
+			p.monthDays = append(p.monthDays, p.dayOrdinal)
+		} else {
+			// "every second day" — через день
+			p.n = p.dayOrdinal * p.n
+		}
+	}
+	if len(p.except) > 0 {
+		if len(p.weekdays) == 0 {
+			return "", errors.New("except without weekdays")
+		}
+		p.weekdays = slices.DeleteFunc(p.weekdays, func(d int) bool { return slices.Contains(p.except, d) })
+		if len(p.weekdays) == 0 {
+			return "", errors.New("no weekdays left")
+		}
+	}
+	kinds := 0
+	for _, set := range []bool{p.working, len(p.byDay) > 0, len(p.monthDays) > 0, len(p.weekdays) > 0} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return "", errors.New("conflicting phrase")
+	}
+
+	switch {
+	case p.working:
+		if p.unit != "" {
+			return "", errors.New("conflicting phrase")
+		}
+		return fmt.Sprintf("b %d", p.n), nil
+	case len(p.byDay) > 0:
+		if p.unit != "" && p.unit != "m" {
+			return "", errors.New("conflicting phrase")
+		}
+		return "RRULE:FREQ=MONTHLY" + rruleInterval(p.n) + ";BYDAY=" + strings.Join(p.byDay, ","), nil
+	case len(p.monthDays) > 0:
+		if p.unit != "" && p.unit != "m" {
+			return "", errors.New("conflicting phrase")
+		}
+		if p.n == 1 {
+			return "m " + joinInts(p.monthDays), nil
+		}
+		return "RRULE:FREQ=MONTHLY" + rruleInterval(p.n) + ";BYMONTHDAY=" + joinInts(p.monthDays), nil
+	case len(p.weekdays) > 0:
+		if p.unit != "" && p.unit != "w" {
+			return "", errors.New("conflicting phrase")
+		}
+		days := uniqueSorted(p.weekdays)
+		if p.n == 1 {
+			return "w " + joinInts(days), nil
+		}
+		codes := make([]string, len(days))
+		for i, d := range days {
+			codes[i] = rruleDayCodes[d]
+		}
+		return "RRULE:FREQ=WEEKLY" + rruleInterval(p.n) + ";BYDAY=" + strings.Join(codes, ","), nil
+	case p.unit == "d":
+		return fmt.Sprintf("d %d", p.n), nil
+	case p.unit == "w":
+		return fmt.Sprintf("d %d", 7*p.n), nil
+	case p.unit == "m":
+		return "RRULE:FREQ=MONTHLY" + rruleInterval(p.n), nil
+	case p.unit == "y":
+		if p.n == 1 {
+			return "y", nil
+		}
+		return "RRULE:FREQ=YEARLY" + rruleInterval(p.n), nil
+	}
+	return "", errors.New("cannot parse phrase")
+}
+
+// rruleInterval — ";INTERVAL=n" для n > 1.
+func rruleInterval(n int) string {
+	if n <= 1 {
+		return ""
+	}
+	return ";INTERVAL=" + strconv.Itoa(n)
+}
+
+func joinInts(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+// uniqueSorted — отсортированные дни недели без повторов.
+func uniqueSorted(ns []int) []int {
+	var seen [8]bool
+	for _, n := range ns {
+		seen[n] = true
+	}
+	var out []int
+	for d := 1; d <= 7; d++ {
+		if seen[d] {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// parseRepeatText переводит фразу в правило и проверяет, что NextDate его принимает.
+func parseRepeatText(text string) (string, error) {
+	repeat, err := ParseRepeatPhrase(text)
+	if err != nil {
+		return "", err
+	}
+	today := time.Now().Format(dateFmt)
+	if _, err := NextDate(time.Now(), today, repeat); err != nil {
+		return "", fmt.Errorf("bad repeat %q: %w", repeat, err)
+	}
+	return repeat, nil
+}
+
+// repeatParseHandler — GET /api/repeat/parse?text=...
+// Ответ: {"repeat":"w 1,4"} или {"error":"..."}.
+func repeatParseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	repeat, err := parseRepeatText(r.FormValue("text"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, map[string]string{"repeat": repeat})
+}
@@ -0,0 +1,201 @@
+// Package api: проекты — отдельные списки задач.
+// GET /api/projects[?archived=1] — список; GET /api/projects?id=... — один проект;
+// POST — создать; PUT — изменить (в том числе убрать в архив);
+// DELETE /api/projects?id=... — удалить (задачи переходят во «Входящие»).
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"todo/pkg/db"
+)
+
+// maxProjectNameLen — наибольшая длина имени проекта в символах.
+const maxProjectNameLen = 128
+
+// projectColor — допустимый цвет проекта: #rgb или #rrggbb.
+var projectColor = regexp.MustCompile(`^#([0-9a-f]{3}|[0-9a-f]{6})$`)
+
+// projectsResp — форма ответа: {"projects":[...]}.
+type projectsResp struct {
+	Projects []*db.Project `json:"projects"`
+}
+
+// checkProject проверяет и нормализует имя и цвет проекта.
+func checkProject(p *db.Project) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("empty name")
+	}
+	if len([]rune(p.Name)) > maxProjectNameLen {
+		return errors.New("name too long")
+	}
+	p.Color = strings.ToLower(strings.TrimSpace(p.Color))
+	if p.Color != "" && !projectColor.MatchString(p.Color) {
+		return errors.New("bad color")
+	}
+	return nil
+}
+
+// checkTaskProject проверяет проект задачи tk (0 — DefaultProject): он должен
+// существовать, а переносить задачи в архивный проект нельзя. before — задача
+// до изменения (nil при создании); задачу, уже лежащую в архивном проекте,
+// менять можно.
+func (a *API) checkTaskProject(tk *db.Task, before *db.Task) (int, error) {
+	if tk.ProjectID == 0 {
+		tk.ProjectID = db.DefaultProject
+	}
+	if tk.ProjectID < 0 {
+		return http.StatusBadRequest, db.ErrProjectNotFound
+	}
+	p, err := a.store.GetProject(fmt.Sprint(tk.ProjectID))
+	if errors.Is(err, db.ErrProjectNotFound) {
+		return http.StatusBadRequest, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("db select error")
+	}
+	if p.Archived && (before == nil || before.ProjectID != p.ID) {
+		return http.StatusBadRequest, errors.New("project is archived")
+	}
+	return 0, nil
+}
+
+// projectError отвечает на ошибку хранилища при изменении проекта.
+func projectError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrProjectNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, db.ErrProjectExists):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, db.ErrDefaultProject):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "db update error")
+	}
+}
+
+// projectsHandler — общий роутер для пути /api/projects.
+func (a *API) projectsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.getProjectsHandler(w, r)
+	case http.MethodPost:
+		a.addProjectHandler(w, r)
+	case http.MethodPut:
+		a.updateProjectHandler(w, r)
+	case http.MethodDelete:
+		a.deleteProjectHandler(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// getProjectsHandler — GET /api/projects: один проект по id или список
+// (архивные — только с ?archived=1).
+func (a *API) getProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		p, err := a.store.GetProject(id)
+		if err != nil {
+			projectError(w, err)
+			return
+		}
+		writeJSON(w, p)
+		return
+	}
+	items, err := a.store.Projects()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "db select error")
+		return
+	}
+	resp := projectsResp{Projects: make([]*db.Project, 0, len(items))}
+	for _, p := range items {
+		if !p.Archived || r.URL.Query().Get("archived") == "1" {
+			resp.Projects = append(resp.Projects, p)
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// addProjectHandler — POST /api/projects {"name": "...", "color": "#3366ff"}.
+func (a *API) addProjectHandler(w http.ResponseWriter, r *http.Request) {
+	p := new(db.Project)
+	if err := json.NewDecoder(r.Body).Decode(p); err != nil {
+		writeError(w, http.StatusBadRequest, "json parse error")
+		return
+	}
+	if err := checkProject(p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := a.store.AddProject(p)
+	if err != nil {
+		projectError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"id": fmt.Sprint(id)})
+}
+
+// updateProjectHandler — PUT /api/projects {"id": "2", ...}: поля, которых
+// нет в запросе, сохраняют текущие значения.
+func (a *API) updateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "json parse error")
+		return
+	}
+	in := new(db.Project)
+	if err := json.Unmarshal(body, in); err != nil {
+		writeError(w, http.StatusBadRequest, "json parse error")
+		return
+	}
+	if in.ID <= 0 {
+		writeError(w, http.StatusBadRequest, "bad id")
+		return
+	}
+	p, err := a.store.GetProject(fmt.Sprint(in.ID))
+	if err != nil {
+		projectError(w, err)
+		return
+	}
+	if err := json.Unmarshal(body, p); err != nil {
+		writeError(w, http.StatusBadRequest, "json parse error")
+		return
+	}
+	if err := checkProject(p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := a.store.UpdateProject(p); err != nil {
+		projectError(w, err)
+		return
+	}
+	writeJSON(w, map[string]any{})
+}
+
+// deleteProjectHandler — DELETE /api/projects?id=...: задачи проекта
+// переходят во «Входящие», удаление попадает в журнал с task_id 0.
+func (a *API) deleteProjectHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "no id")
+		return
+	}
+	p, err := a.store.GetProject(id)
+	if err == nil {
+		err = a.store.DeleteProject(id)
+	}
+	if err != nil {
+		projectError(w, err)
+		return
+	}
+	diff, _ := json.Marshal(map[string][2]any{"project": {p.Name, nil}})
+	a.addAudit(r, &db.AuditEntry{Action: auditProjectDelete, Diff: string(diff)})
+	writeJSON(w, map[string]any{})
+}
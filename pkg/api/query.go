@@ -0,0 +1,211 @@
+// Package api: язык запросов для поиска задач (GET /api/tasks?search=...).
+//
+//	from:01.03.2025 to:31.03.2025 repeat:yes "quarterly report" -draft
+//
+// Элементы разделяются пробелами и объединяются по "И":
+//   - слово              — в title/comment есть слово, начинающееся с него;
+//   - "фраза"            — слова фразы целиком и подряд;
+//   - -слово, -"фраза"   — отрицание: такие задачи исключаются;
+//   - from:ДАТА, to:ДАТА — диапазон дат задачи включительно (02.01.2006 или 20060102);
+//   - repeat:yes|no      — только повторяющиеся или только разовые задачи;
+//   - tag:метка, -tag:метка — задачи с меткой / без неё (можно несколько);
+//   - ДАТА (02.01.2006)  — задачи на конкретную дату (как прежний поиск по дате).
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"todo/pkg/db"
+)
+
+// QuerySyntaxError — ошибка разбора запроса; Pos — номер символа (с 0).
+type QuerySyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("search: %s at position %d", e.Msg, e.Pos)
+}
+
+// queryLexer — посимвольный разбор строки запроса.
+type queryLexer struct {
+	s   []rune
+	pos int
+}
+
+// ParseQuery разбирает строку поиска в db.Query.
+// Ошибки синтаксиса возвращаются как *QuerySyntaxError.
+func ParseQuery(search string) (db.Query, error) {
+	var q db.Query
+	lx := &queryLexer{s: []rune(search)}
+	seen := map[string]bool{}
+	toPos := 0 // где задана верхняя граница — для ошибки "from is after to"
+	fail := func(pos int, format string, args ...any) (db.Query, error) {
+		return db.Query{}, &QuerySyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	for {
+		lx.skipSpace()
+		if lx.eof() {
+			break
+		}
+		start := lx.pos
+		neg := lx.peek() == '-'
+		if neg {
+			lx.pos++
+			if lx.eof() || unicode.IsSpace(lx.peek()) {
+				return fail(start, "empty negation")
+			}
+		}
+
+		// "фраза"
+		if lx.peek() == '"' {
+			text, ok := lx.quoted()
+			if !ok {
+				return fail(lx.pos, "unterminated quote")
+			}
+			if strings.TrimSpace(text) == "" {
+				return fail(start, "empty phrase")
+			}
+			term := db.Term{Text: text, Exact: true}
+			if neg {
+				q.Exclude = append(q.Exclude, term)
+			} else {
+				q.Include = append(q.Include, term)
+			}
+			continue
+		}
+
+		wordPos := lx.pos
+		word := lx.word()
+		if i := strings.IndexRune(word, '"'); i >= 0 {
+			return fail(wordPos+len([]rune(word[:i])), "unexpected quote")
+		}
+
+		// фильтр ключ:значение; прочие слова с двоеточием ("18:00", "Note:",
+		// "https://example.com") — обычные слова
+		if key, value, ok := strings.Cut(word, ":"); ok && isQueryKey(key) {
+			key = strings.ToLower(key)
+			if value == "" {
+				return fail(start, "missing value for %s", key)
+			}
+			valuePos := wordPos + len([]rune(key)) + 1
+			if key == "tag" {
+				name, err := normalizeTag(value)
+				if err != nil {
+					return fail(valuePos, "bad tag %q", value)
+				}
+				if neg {
+					q.NotTags = append(q.NotTags, name)
+				} else {
+					q.Tags = append(q.Tags, name)
+				}
+				continue
+			}
+			if neg {
+				return fail(start, "filter %s cannot be negated", key)
+			}
+			if seen[key] {
+				return fail(start, "duplicate filter %s", key)
+			}
+			seen[key] = true
+
+			switch key {
+			case "from", "to":
+				d, ok := parseQueryDate(value)
+				if !ok {
+					return fail(valuePos, "bad date %q", value)
+				}
+				if key == "from" {
+					q.From = d
+				} else {
+					q.To, toPos = d, start
+				}
+			case "repeat":
+				switch strings.ToLower(value) {
+				case db.RepeatYes, db.RepeatNo:
+					q.Repeat = strings.ToLower(value)
+				default:
+					return fail(valuePos, "repeat must be yes or no")
+				}
+			}
+			continue
+		}
+
+		// дата сама по себе — задачи на этот день
+		if d, err := time.Parse("02.01.2006", word); err == nil && !neg {
+			if seen["from"] || seen["to"] {
+				return fail(start, "date conflicts with from/to")
+			}
+			seen["from"], seen["to"] = true, true
+			q.From = d.Format(dateFmt)
+			q.To, toPos = q.From, start
+			continue
+		}
+
+		term := db.Term{Text: word}
+		if neg {
+			q.Exclude = append(q.Exclude, term)
+		} else {
+			q.Include = append(q.Include, term)
+		}
+	}
+
+	if q.From != "" && q.To != "" && q.From > q.To {
+		return fail(toPos, "from is after to")
+	}
+	return q, nil
+}
+
+func (lx *queryLexer) eof() bool  { return lx.pos >= len(lx.s) }
+func (lx *queryLexer) peek() rune { return lx.s[lx.pos] }
+
+func (lx *queryLexer) skipSpace() {
+	for !lx.eof() && unicode.IsSpace(lx.peek()) {
+		lx.pos++
+	}
+}
+
+// word — всё до ближайшего пробела.
+func (lx *queryLexer) word() string {
+	start := lx.pos
+	for !lx.eof() && !unicode.IsSpace(lx.peek()) {
+		lx.pos++
+	}
+	return string(lx.s[start:lx.pos])
+}
+
+// quoted — текст между кавычками; pos стоит на открывающей кавычке.
+// Если закрывающей нет, pos остаётся на открывающей и ok == false.
+func (lx *queryLexer) quoted() (string, bool) {
+	open := lx.pos
+	for i := open + 1; i < len(lx.s); i++ {
+		if lx.s[i] == '"' {
+			lx.pos = i + 1
+			return string(lx.s[open+1 : i]), true
+		}
+	}
+	return "", false
+}
+
+// queryKeys — имена фильтров ключ:значение.
+var queryKeys = map[string]bool{"from": true, "to": true, "repeat": true, "tag": true}
+
+// isQueryKey — имя ли это фильтра (без учёта регистра).
+func isQueryKey(s string) bool {
+	return queryKeys[strings.ToLower(s)]
+}
+
+// parseQueryDate принимает 02.01.2006 или 20060102 и возвращает 20060102.
+func parseQueryDate(s string) (string, bool) {
+	for _, layout := range []string{"02.01.2006", dateFmt} {
+		if d, err := time.Parse(layout, s); err == nil {
+			return d.Format(dateFmt), true
+		}
+	}
+	return "", false
+}
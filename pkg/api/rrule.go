@@ -0,0 +1,466 @@
+// Package api: поддержка правил повторения в формате iCalendar (RFC 5545).
+// Строка вида "RRULE:FREQ=MONTHLY;INTERVAL=3;BYDAY=2TU" разбирается в rrule
+// и разворачивается в последовательность дат начиная с даты задачи (DTSTART).
+package api
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rrulePrefix — признак правила iCalendar в поле repeat.
+const rrulePrefix = "RRULE:"
+
+// weekdayNum — элемент BYDAY: день недели с необязательным порядковым номером
+// (2TU — второй вторник, -1FR — последняя пятница, MO — любой понедельник).
+type weekdayNum struct {
+	n  int
+	wd time.Weekday
+}
+
+// rrule — разобранное правило повторения.
+// Нулевые значения полей означают "не задано".
+type rrule struct {
+	freq       string
+	interval   int
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []int
+	bySetPos   []int
+	count      int
+	until      time.Time
+	wkst       time.Weekday
+}
+
+// rruleDays — коды дней недели из RFC 5545.
+var rruleDays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// isRRule сообщает, записано ли правило в формате iCalendar.
+func isRRule(repeat string) bool {
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(repeat)), rrulePrefix)
+}
+
+// parseRRule разбирает строку "RRULE:KEY=VALUE;KEY=VALUE...".
+// Поддержаны FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, BYDAY,
+// BYMONTHDAY, BYMONTH, BYSETPOS, COUNT, UNTIL и WKST.
+func parseRRule(s string) (*rrule, error) {
+	s = strings.TrimSpace(s)
+	if !isRRule(s) {
+		return nil, errors.New("bad rrule prefix")
+	}
+	s = s[len(rrulePrefix):]
+
+	r := &rrule{interval: 1, wkst: time.Monday}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, errors.New("bad rrule part")
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		val = strings.ToUpper(strings.TrimSpace(val))
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch val {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				r.freq = val
+			default:
+				return nil, errors.New("unsupported rrule freq")
+			}
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(val)
+			if err != nil || r.interval < 1 {
+				return nil, errors.New("bad rrule interval")
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(val)
+			if err != nil || r.count < 1 {
+				return nil, errors.New("bad rrule count")
+			}
+		case "UNTIL":
+			// дата (20240131) или дата-время (20240131T235959Z) — берём только дату
+			if len(val) < 8 {
+				return nil, errors.New("bad rrule until")
+			}
+			r.until, err = time.Parse(dateFmt, val[:8])
+			if err != nil {
+				return nil, errors.New("bad rrule until")
+			}
+		case "BYDAY":
+			for _, f := range strings.Split(val, ",") {
+				wn, err := parseWeekdayNum(f)
+				if err != nil {
+					return nil, err
+				}
+				r.byDay = append(r.byDay, wn)
+			}
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseIntsRange(val, 1, 31, true)
+			if err != nil {
+				return nil, errors.New("bad rrule bymonthday")
+			}
+		case "BYMONTH":
+			r.byMonth, err = parseIntsRange(val, 1, 12, false)
+			if err != nil {
+				return nil, errors.New("bad rrule bymonth")
+			}
+		case "BYSETPOS":
+			r.bySetPos, err = parseIntsRange(val, 1, 366, true)
+			if err != nil {
+				return nil, errors.New("bad rrule bysetpos")
+			}
+		case "WKST":
+			wd, ok := rruleDays[val]
+			if !ok {
+				return nil, errors.New("bad rrule wkst")
+			}
+			r.wkst = wd
+		default:
+			return nil, errors.New("unsupported rrule part " + key)
+		}
+	}
+
+	if r.freq == "" {
+		return nil, errors.New("rrule freq is required")
+	}
+	if r.count > 0 && !r.until.IsZero() {
+		return nil, errors.New("rrule count and until are exclusive")
+	}
+	// порядковые номера в BYDAY имеют смысл только внутри месяца или года
+	if r.freq == "DAILY" || r.freq == "WEEKLY" {
+		for _, wn := range r.byDay {
+			if wn.n != 0 {
+				return nil, errors.New("bad rrule byday ordinal")
+			}
+		}
+	}
+	return r, nil
+}
+
+// parseWeekdayNum разбирает элемент BYDAY: "[+|-][N]XX".
+func parseWeekdayNum(s string) (weekdayNum, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return weekdayNum{}, errors.New("bad rrule byday")
+	}
+	wd, ok := rruleDays[s[len(s)-2:]]
+	if !ok {
+		return weekdayNum{}, errors.New("bad rrule byday")
+	}
+	wn := weekdayNum{wd: wd}
+	if num := s[:len(s)-2]; num != "" {
+		n, err := strconv.Atoi(num)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return weekdayNum{}, errors.New("bad rrule byday")
+		}
+		wn.n = n
+	}
+	return wn, nil
+}
+
+// parseIntsRange разбирает список чисел и проверяет, что |n| в диапазоне lo..hi.
+// Отрицательные значения допускаются только при neg == true.
+func parseIntsRange(s string, lo, hi int, neg bool) ([]int, error) {
+	ns, err := parseInts(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range ns {
+		abs := n
+		if n < 0 {
+			if !neg {
+				return nil, errors.New("negative value")
+			}
+			abs = -n
+		}
+		if abs < lo || abs > hi {
+			return nil, errors.New("value out of range")
+		}
+	}
+	return ns, nil
+}
+
+// nextRRule возвращает первую дату правила, которая строго позже now и start.
+// Дата задачи start считается DTSTART и первым вхождением серии (для COUNT).
+func nextRRule(now, start time.Time, repeat string) (string, error) {
+	r, err := parseRRule(repeat)
+	if err != nil {
+		return "", err
+	}
+	ref := start
+	if afterNow(now, ref) {
+		ref = now
+	}
+
+	var next string
+	r.each(start, ref.AddDate(0, 0, maxScanDays), func(d time.Time) bool {
+		if afterNow(d, ref) {
+			next = d.Format(dateFmt)
+			return false
+		}
+		return true
+	})
+	if next == "" {
+		return "", errNoOccurrences
+	}
+	return next, nil
+}
+
+// rruleCount возвращает COUNT правила RRULE (0 — не задан или правило другое).
+// Дата задачи сдвигается при каждом выполнении, поэтому COUNT от неё не
+// отсчитывается: для задачи он ограничивает серию вместе с RepeatDone, как
+// repeat_count (см. nextOccurrence).
+func rruleCount(repeat string) int64 {
+	base, _, _ := strings.Cut(repeat, "|")
+	if !isRRule(base) {
+		return 0
+	}
+	r, err := parseRRule(base)
+	if err != nil {
+		return 0 // ошибку правила сообщит NextDate
+	}
+	return int64(r.count)
+}
+
+// each перебирает вхождения правила по возрастанию, начиная с start
+// (start всегда первое вхождение), пока fn возвращает true.
+// Перебор ограничен датой horizon, а также COUNT/UNTIL самого правила.
+func (r *rrule) each(start, horizon time.Time, fn func(time.Time) bool) {
+	start = dayOf(start)
+	emitted := 0
+	emit := func(d time.Time) bool {
+		if !r.until.IsZero() && d.After(r.until) {
+			return false
+		}
+		emitted++
+		if !fn(d) {
+			return false
+		}
+		return r.count == 0 || emitted < r.count
+	}
+	if !emit(start) {
+		return
+	}
+
+	for p := 0; ; p++ {
+		from := r.periodStart(start, p)
+		if from.After(horizon) {
+			return
+		}
+		for _, d := range r.expand(start, from) {
+			if !d.After(start) {
+				continue
+			}
+			if !emit(d) {
+				return
+			}
+		}
+	}
+}
+
+// periodStart возвращает первый день p-го периода правила.
+func (r *rrule) periodStart(start time.Time, p int) time.Time {
+	step := p * r.interval
+	switch r.freq {
+	case "DAILY":
+		return start.AddDate(0, 0, step)
+	case "WEEKLY":
+		shift := (int(start.Weekday()) - int(r.wkst) + 7) % 7
+		return start.AddDate(0, 0, step*7-shift)
+	case "MONTHLY":
+		return time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+	default: // YEARLY
+		return time.Date(start.Year()+step, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// expand возвращает отсортированные даты-кандидаты внутри периода,
+// начинающегося с from, с учётом BYxxx и BYSETPOS.
+func (r *rrule) expand(start, from time.Time) []time.Time {
+	var set []time.Time
+	switch r.freq {
+	case "DAILY":
+		if r.matchDay(from) {
+			set = append(set, from)
+		}
+	case "WEEKLY":
+		for i := 0; i < 7; i++ {
+			d := from.AddDate(0, 0, i)
+			if !r.inMonths(d.Month()) {
+				continue
+			}
+			if len(r.byDay) == 0 && d.Weekday() != start.Weekday() {
+				continue
+			}
+			if len(r.byDay) > 0 && !r.matchWeekday(d) {
+				continue
+			}
+			set = append(set, d)
+		}
+	case "MONTHLY":
+		if r.inMonths(from.Month()) {
+			set = r.expandMonth(start, from)
+		}
+	default: // YEARLY
+		switch {
+		case len(r.byMonth) == 0 && len(r.byMonthDay) == 0 && len(r.byDay) > 0:
+			// BYDAY с номерами в пределах года (например, 20MO — 20-й понедельник года)
+			end := from.AddDate(1, 0, 0)
+			for d := from; d.Before(end); d = d.AddDate(0, 0, 1) {
+				if r.matchOrdinal(d, from, end) {
+					set = append(set, d)
+				}
+			}
+		case len(r.byMonth) == 0 && len(r.byMonthDay) == 0:
+			// без уточнений — тот же день и месяц, что у DTSTART
+			d := time.Date(from.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			if d.Month() == start.Month() {
+				set = append(set, d)
+			}
+		default:
+			for m := time.January; m <= time.December; m++ {
+				if !r.inMonths(m) {
+					continue
+				}
+				month := time.Date(from.Year(), m, 1, 0, 0, 0, 0, time.UTC)
+				set = append(set, r.expandMonth(start, month)...)
+			}
+		}
+	}
+	return r.applySetPos(set)
+}
+
+// expandMonth — кандидаты внутри одного месяца (from — первое число месяца).
+func (r *rrule) expandMonth(start, from time.Time) []time.Time {
+	end := from.AddDate(0, 1, 0)
+	last := end.AddDate(0, 0, -1).Day()
+
+	if len(r.byMonthDay) == 0 && len(r.byDay) == 0 {
+		// тот же день месяца, что у DTSTART; если его нет — месяц пропускается
+		if start.Day() > last {
+			return nil
+		}
+		return []time.Time{from.AddDate(0, 0, start.Day()-1)}
+	}
+
+	var set []time.Time
+	for d := from; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if len(r.byMonthDay) > 0 && !matchMonthDay(d.Day(), last, r.byMonthDay) {
+			continue
+		}
+		if len(r.byDay) > 0 && !r.matchOrdinal(d, from, end) {
+			continue
+		}
+		set = append(set, d)
+	}
+	return set
+}
+
+// matchDay — фильтры BYMONTH/BYMONTHDAY/BYDAY для ежедневного правила.
+func (r *rrule) matchDay(d time.Time) bool {
+	if !r.inMonths(d.Month()) {
+		return false
+	}
+	if len(r.byMonthDay) > 0 {
+		last := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		if !matchMonthDay(d.Day(), last, r.byMonthDay) {
+			return false
+		}
+	}
+	if len(r.byDay) > 0 && !r.matchWeekday(d) {
+		return false
+	}
+	return true
+}
+
+// inMonths — входит ли месяц в BYMONTH (пустой список — любой месяц).
+func (r *rrule) inMonths(m time.Month) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+	for _, n := range r.byMonth {
+		if time.Month(n) == m {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekday — совпадает ли день недели с одним из BYDAY (без учёта номеров).
+func (r *rrule) matchWeekday(d time.Time) bool {
+	for _, wn := range r.byDay {
+		if wn.wd == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrdinal — проверка BYDAY с порядковыми номерами внутри интервала [from, end):
+// 2TU — второй вторник интервала, -1FR — последняя пятница, TU — любой вторник.
+func (r *rrule) matchOrdinal(d, from, end time.Time) bool {
+	for _, wn := range r.byDay {
+		if wn.wd != d.Weekday() {
+			continue
+		}
+		switch {
+		case wn.n == 0:
+			return true
+		case wn.n > 0 && int(d.Sub(from).Hours()/24)/7+1 == wn.n:
+			return true
+		case wn.n < 0 && int(end.Sub(d).Hours()/24-1)/7+1 == -wn.n:
+			return true
+		}
+	}
+	return false
+}
+
+// matchMonthDay — совпадает ли день day (в месяце из last дней) с BYMONTHDAY.
+func matchMonthDay(day, last int, list []int) bool {
+	for _, n := range list {
+		if n > 0 && day == n || n < 0 && day == last+n+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// applySetPos оставляет из набора кандидатов только позиции из BYSETPOS.
+func (r *rrule) applySetPos(set []time.Time) []time.Time {
+	if len(r.bySetPos) == 0 || len(set) == 0 {
+		return set
+	}
+	var out []time.Time
+	for _, pos := range r.bySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(set) + pos
+		}
+		if i >= 0 && i < len(set) {
+			out = append(out, set[i])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
+
+// dayOf отбрасывает время и часовой пояс, оставляя только дату (в UTC).
+func dayOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,105 @@
+// Package api: метки задач.
+// GET /api/tags — все метки с числом задач;
+// PUT /api/tags?name=...&to=... — переименовать; DELETE /api/tags?name=... — удалить.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"todo/pkg/db"
+)
+
+// maxTagLen — наибольшая длина метки в символах.
+const maxTagLen = 64
+
+// normalizeTag приводит метку к каноническому виду: без # в начале,
+// в нижнем регистре. Допустимы буквы, цифры и символы - _ . /
+func normalizeTag(s string) (string, error) {
+	name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), "#"))
+	if name == "" || len([]rune(name)) > maxTagLen {
+		return "", fmt.Errorf("bad tag %q", s)
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_./", r) {
+			return "", fmt.Errorf("bad tag %q", s)
+		}
+	}
+	return name, nil
+}
+
+// checkTags нормализует метки задачи (повторы убирает хранилище).
+func checkTags(tk *db.Task) error {
+	for i, s := range tk.Tags {
+		name, err := normalizeTag(s)
+		if err != nil {
+			return err
+		}
+		tk.Tags[i] = name
+	}
+	return nil
+}
+
+// tagsResp — форма ответа: {"tags":[{"name":"work","tasks":3}, ...]}.
+type tagsResp struct {
+	Tags []db.TagCount `json:"tags"`
+}
+
+// tagsHandler — список (GET), переименование (PUT) и удаление (DELETE) меток.
+// Переименование и удаление меняют метки у всех задач и попадают в журнал
+// изменений с task_id 0.
+func (a *API) tagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		items, err := a.store.Tags()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "db select error")
+			return
+		}
+		writeJSON(w, tagsResp{Tags: items})
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	name, err := normalizeTag(r.URL.Query().Get("name"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, action := "", auditTagDelete
+	if r.Method == http.MethodPut {
+		if to, err = normalizeTag(r.URL.Query().Get("to")); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		action = auditTagRename
+		err = a.store.RenameTag(name, to)
+	} else {
+		err = a.store.DeleteTag(name)
+	}
+	switch {
+	case errors.Is(err, db.ErrTagNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, db.ErrTagExists):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "db update error")
+		return
+	}
+
+	var after any
+	if to != "" {
+		after = to
+	}
+	diff, _ := json.Marshal(map[string][2]any{"tag": {name, after}})
+	a.addAudit(r, &db.AuditEntry{Action: action, Diff: string(diff)})
+	writeJSON(w, map[string]any{})
+}
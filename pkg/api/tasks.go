@@ -1,44 +1,160 @@
 // Package api: обработчик списка задач с опциональным поиском.
-// GET /api/tasks[?search=...][&limit=N]
+// GET /api/tasks[?search=...][&tag=...][&project=ID][&sort=КЛЮЧ][&limit=N][&cursor=...][&total=1]
 package api
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"todo/pkg/db"
 )
 
-// tasksResp — форма ответа: {"tasks":[...]}.
+// tasksResp — форма ответа: {"tasks":[...]}, при наличии следующей страницы —
+// "next_cursor", с ?total=1 — "total" (сколько всего задач подходит под запрос).
 // Элементы — напрямую db.Task (id сериализуется строкой через тег json:",string").
 type tasksResp struct {
-	Tasks []*db.Task `json:"tasks"`
+	Tasks      []*db.Task `json:"tasks"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	Total      *int       `json:"total,omitempty"`
+}
+
+// pageCursor — содержимое next_cursor: сортировка и отпечаток строки поиска,
+// для которых он выдан, и позиция в списке. Клиенту курсор непрозрачен:
+// JSON в base64url.
+type pageCursor struct {
+	Sort   string `json:"s,omitempty"`
+	Desc   bool   `json:"d,omitempty"`
+	Search uint32 `json:"q,omitempty"`
+	Value  string `json:"v,omitempty"`
+	ID     int64  `json:"i,omitempty"`
+	Offset int    `json:"o,omitempty"`
+}
+
+func (c pageCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// parseCursor разбирает строку, полученную из pageCursor.String.
+func parseCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &c) != nil || c.ID < 0 || c.Offset < 0 {
+		return pageCursor{}, errors.New("bad cursor")
+	}
+	return c, nil
+}
+
+// searchPrint — отпечаток строки поиска, меток и проекта: курсор годится
+// только для того же запроса.
+func searchPrint(search string, tags []string, project int64) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(strings.TrimSpace(search) + "\n" + strings.Join(tags, ",") + "\n" + strconv.FormatInt(project, 10)))
+	return h.Sum32()
+}
+
+// parseSort разбирает ключ сортировки: date, title, id, created или priority,
+// с минусом впереди — по убыванию.
+func parseSort(s string) (key string, desc bool, err error) {
+	key, desc = strings.CutPrefix(s, "-")
+	switch key {
+	case db.SortDate, db.SortTitle, db.SortID, db.SortCreated, db.SortPriority:
+		return key, desc, nil
+	}
+	return "", false, errors.New("bad sort")
 }
 
 // tasksHandler — обрабатывает GET /api/tasks.
-// Поддерживает ограничение limit и поиск search
-// (подстрока в title/comment или дата 02.01.2006).
-func tasksHandler(w http.ResponseWriter, r *http.Request) {
+// Поддерживает поиск search на языке запросов (см. query.go), отбор по меткам
+// tag (можно несколько — нужны все), задачи одного проекта project, сортировку sort и постраничную выдачу: limit задач, следующая страница — с cursor=next_cursor
+// (sort и search повторять не обязательно, но если заданы — должны совпадать).
+// Ошибка в запросе — 400 и {"error":"...","position":N}.
+func (a *API) tasksHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	params := r.URL.Query()
+	search := params.Get("search")
 
-	search := r.URL.Query().Get("search")
+	q, err := ParseQuery(search)
+	if err != nil {
+		var se *QuerySyntaxError
+		if errors.As(err, &se) {
+			writeJSONStatus(w, http.StatusBadRequest, map[string]any{"error": se.Error(), "position": se.Pos})
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, s := range params["tag"] {
+		name, err := normalizeTag(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		q.Tags = append(q.Tags, name)
+	}
+	if s := params.Get("project"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "bad project")
+			return
+		}
+		q.Project = n
+	}
 
 	// дефолтный лимит берём из константы пакета
-	limit := defaultTasksLimit
-	if s := r.URL.Query().Get("limit"); s != "" {
+	p := db.Page{Limit: defaultTasksLimit, Total: params.Get("total") == "1"}
+	if s := params.Get("limit"); s != "" {
 		if n, err := strconv.Atoi(s); err == nil && n > 0 {
-			limit = n
+			p.Limit = min(n, maxTasksLimit)
+		}
+	}
+	if s := params.Get("sort"); s != "" {
+		if p.Sort, p.Desc, err = parseSort(s); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
 		}
 	}
+	if s := params.Get("cursor"); s != "" {
+		c, err := parseCursor(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if params.Has("sort") && (c.Sort != p.Sort || c.Desc != p.Desc) {
+			writeError(w, http.StatusBadRequest, "cursor does not match sort")
+			return
+		}
+		if c.Search != searchPrint(search, params["tag"], q.Project) {
+			writeError(w, http.StatusBadRequest, "cursor does not match search")
+			return
+		}
+		p.Sort, p.Desc = c.Sort, c.Desc
+		p.After = &db.Cursor{Value: c.Value, ID: c.ID, Offset: c.Offset}
+	}
 
-	items, err := db.Tasks(limit, search)
+	page, err := a.store.Tasks(q, p)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "db select error")
 		return
 	}
 
-	writeJSON(w, tasksResp{Tasks: items})
+	resp := tasksResp{Tasks: page.Tasks}
+	if page.Next != nil {
+		resp.NextCursor = pageCursor{
+			Sort: p.Sort, Desc: p.Desc, Search: searchPrint(search, params["tag"], q.Project),
+			Value: page.Next.Value, ID: page.Next.ID, Offset: page.Next.Offset,
+		}.String()
+	}
+	if p.Total {
+		resp.Total = &page.Total
+	}
+	writeJSON(w, resp)
 }
@@ -0,0 +1,100 @@
+// Package api: корзина удалённых задач.
+// GET /api/trash — список; POST /api/trash/restore?id=... — вернуть задачу;
+// DELETE /api/trash?id=... — удалить навсегда (?all=1 — очистить корзину).
+package api
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultTrashDays — сколько дней задачи хранятся в корзине по умолчанию.
+const defaultTrashDays = 30
+
+// trashRetentionFromEnv читает срок хранения из TODO_TRASH_DAYS (в днях;
+// 0 — не очищать автоматически). Неверное значение — срок по умолчанию.
+func trashRetentionFromEnv() time.Duration {
+	days := defaultTrashDays
+	if s := os.Getenv("TODO_TRASH_DAYS"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			days = n
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// SetTrashRetention задаёт срок хранения задач в корзине вместо TODO_TRASH_DAYS
+// (0 — не очищать автоматически).
+func (a *API) SetTrashRetention(d time.Duration) {
+	a.trashRetention = d
+}
+
+// PurgeTrash окончательно удаляет задачи, пролежавшие в корзине дольше срока
+// хранения, и возвращает их число. Вызывается периодически (см. server.Start).
+func (a *API) PurgeTrash() (int64, error) {
+	if a.trashRetention <= 0 {
+		return 0, nil
+	}
+	before := time.Now().Add(-a.trashRetention).UTC().Format(time.RFC3339)
+	return a.store.PurgeDeleted(before)
+}
+
+// trashHandler — список задач в корзине (GET) и окончательное удаление (DELETE).
+func (a *API) trashHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := a.store.Trash()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "db select error")
+			return
+		}
+		writeJSON(w, tasksResp{Tasks: items})
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if r.URL.Query().Get("all") == "1" && id == "" {
+			// все задачи в корзине удалены раньше текущего момента
+			n, err := a.store.PurgeDeleted(time.Now().Add(time.Second).UTC().Format(time.RFC3339))
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "purge error")
+				return
+			}
+			// task_id 0 — очищена вся корзина
+			a.audit(r, auditPurge, 0, nil, nil)
+			writeJSON(w, map[string]int64{"purged": n})
+			return
+		}
+		if id == "" {
+			writeError(w, http.StatusBadRequest, "no id")
+			return
+		}
+		if err := a.store.PurgeTask(id); err != nil {
+			writeError(w, http.StatusNotFound, "task not in trash")
+			return
+		}
+		n, _ := strconv.ParseInt(id, 10, 64)
+		a.audited(w, r, auditPurge, n, nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// restoreHandler — POST /api/trash/restore?id=...
+func (a *API) restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "no id")
+		return
+	}
+	if err := a.store.RestoreTask(id); err != nil {
+		writeError(w, http.StatusNotFound, "task not in trash")
+		return
+	}
+	n, _ := strconv.ParseInt(id, 10, 64)
+	a.audited(w, r, auditRestore, n, nil)
+}
@@ -0,0 +1,138 @@
+// Package api: правила повторения с учётом рабочих дней и праздников.
+// Календари праздников загружает пакет calendar (каталог TODO_HOLIDAYS,
+// по умолчанию ./holidays); страна по умолчанию — TODO_HOLIDAYS_COUNTRY.
+package api
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo/pkg/calendar"
+)
+
+// defaultCountry — календарь для правил, где страна не указана явно.
+// Пустая строка — рабочими считаются все дни кроме субботы и воскресенья.
+var defaultCountry string
+
+// setCalendarFromEnv читает настройки календарей (вызываем из api.New()).
+// Календари общие для процесса: NextDate — чистая функция без состояния API.
+func setCalendarFromEnv() {
+	if dir := os.Getenv("TODO_HOLIDAYS"); dir != "" {
+		calendar.SetDir(dir)
+	}
+	defaultCountry = os.Getenv("TODO_HOLIDAYS_COUNTRY")
+}
+
+// workCalendar возвращает календарь страны country (или страны по умолчанию).
+func workCalendar(country string) (*calendar.Calendar, error) {
+	if country == "" {
+		country = defaultCountry
+	}
+	return calendar.Get(country)
+}
+
+// addWorkdays сдвигает d на n рабочих дней (n < 0 — назад).
+// Если рабочих дней не находится за maxScanDays, календарь считается некорректным.
+func addWorkdays(cal *calendar.Calendar, d time.Time, n int) (time.Time, error) {
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for i, scanned := 0, 0; i < n; scanned++ {
+		if scanned > maxScanDays {
+			return d, errors.New("no working days in calendar")
+		}
+		d = d.AddDate(0, 0, step)
+		if cal.IsWorkday(d) {
+			i++
+		}
+	}
+	return d, nil
+}
+
+// nextWorkdays — правило "b N [страна]": через N рабочих дней (1..400).
+func nextWorkdays(now, start time.Time, args []string) (string, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return "", errors.New("bad b format")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return "", errors.New("bad b number")
+	}
+	if n <= 0 || n > 400 {
+		return "", errors.New("bad b interval")
+	}
+	country := ""
+	if len(args) == 2 {
+		country = args[1]
+	}
+	cal, err := workCalendar(country)
+	if err != nil {
+		return "", err
+	}
+
+	d := start
+	for {
+		if d, err = addWorkdays(cal, d, n); err != nil {
+			return "", err
+		}
+		if afterNow(d, now) {
+			return d.Format(dateFmt), nil
+		}
+	}
+}
+
+// nextShifted — модификатор "<правило> | next [страна]" или "<правило> | prev [страна]":
+// если очередная дата правила выпадает на выходной или праздник, она переносится
+// на ближайший рабочий день вперёд (next) или назад (prev).
+// dstart — дата по расписанию (до переноса): от неё отсчитываются интервалы d/y,
+// иначе серия "уплывала" бы вслед за каждым переносом. Кроме перенесённой даты
+// возвращается и дата по расписанию, если они различаются (иначе — пусто).
+func nextShifted(now time.Time, dstart, base, mod string) (string, string, error) {
+	args := strings.Fields(mod)
+	if len(args) < 1 || len(args) > 2 {
+		return "", "", errors.New("bad shift format")
+	}
+	var step int
+	switch args[0] {
+	case "next":
+		step = 1
+	case "prev":
+		step = -1
+	default:
+		return "", "", errors.New("bad shift direction")
+	}
+	country := ""
+	if len(args) == 2 {
+		country = args[1]
+	}
+	cal, err := workCalendar(country)
+	if err != nil {
+		return "", "", err
+	}
+
+	from := now
+	for range maxScanDays {
+		next, err := NextDate(from, dstart, base)
+		if err != nil {
+			return "", "", err
+		}
+		d, _ := time.Parse(dateFmt, next)
+		scheduled := ""
+		if !cal.IsWorkday(d) {
+			if d, err = addWorkdays(cal, d, step); err != nil {
+				return "", "", err
+			}
+			scheduled = next
+		}
+		// перенос назад мог вернуть дату в прошлое — берём следующую дату правила
+		if afterNow(d, now) {
+			return d.Format(dateFmt), scheduled, nil
+		}
+		from, _ = time.Parse(dateFmt, next)
+	}
+	return "", "", errors.New("no matching date")
+}
@@ -0,0 +1,196 @@
+// Package calendar загружает производственные календари (праздники и
+// перенесённые рабочие дни) из локальных файлов и отвечает на вопрос
+// "рабочий ли это день". Календари лежат в каталоге (по умолчанию ./holidays)
+// в файлах <страна>.json или <страна>.ics, например holidays/ru.json.
+package calendar
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dateFmt — формат дат в файлах календаря и в ключах (YYYYMMDD).
+const dateFmt = "20060102"
+
+// Calendar — нерабочие и рабочие дни одной страны.
+// nil-календарь означает "только субботы и воскресенья".
+type Calendar struct {
+	annual   map[string]bool // ежегодные праздники, ключ MMDD
+	holidays map[string]bool // конкретные нерабочие дни, ключ YYYYMMDD
+	workdays map[string]bool // рабочие субботы/воскресенья (переносы), ключ YYYYMMDD
+}
+
+// fileJSON — формат JSON-файла календаря:
+//
+//	{"annual": ["0101", "0223"], "holidays": ["20250502"], "workdays": ["20251101"]}
+type fileJSON struct {
+	Annual   []string `json:"annual"`
+	Holidays []string `json:"holidays"`
+	Workdays []string `json:"workdays"`
+}
+
+var (
+	mu    sync.Mutex
+	dir   = "holidays"
+	cache = map[string]*Calendar{}
+)
+
+// SetDir задаёт каталог с файлами календарей и сбрасывает кеш.
+func SetDir(d string) {
+	mu.Lock()
+	defer mu.Unlock()
+	dir = d
+	cache = map[string]*Calendar{}
+}
+
+// Get возвращает календарь страны (код из латинских букв, например "ru").
+// Файл читается один раз и кешируется. Пустой код — nil-календарь (только выходные).
+func Get(country string) (*Calendar, error) {
+	country = strings.ToLower(strings.TrimSpace(country))
+	if country == "" {
+		return nil, nil
+	}
+	for _, r := range country {
+		if r < 'a' || r > 'z' {
+			return nil, fmt.Errorf("bad calendar name %q", country)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if c, ok := cache[country]; ok {
+		return c, nil
+	}
+
+	var c *Calendar
+	var err error
+	base := filepath.Join(dir, country)
+	if f, e := os.Open(base + ".json"); e == nil {
+		c, err = ParseJSON(f)
+		f.Close()
+	} else if f, e := os.Open(base + ".ics"); e == nil {
+		c, err = ParseICS(f)
+		f.Close()
+	} else {
+		return nil, fmt.Errorf("unknown calendar %q", country)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("calendar %q: %w", country, err)
+	}
+	cache[country] = c
+	return c, nil
+}
+
+// ParseJSON читает календарь в формате fileJSON.
+func ParseJSON(r io.Reader) (*Calendar, error) {
+	var in fileJSON
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+	c := newCalendar()
+	for _, s := range in.Annual {
+		if _, err := time.Parse("0102", s); err != nil {
+			return nil, fmt.Errorf("bad annual date %q", s)
+		}
+		c.annual[s] = true
+	}
+	for _, s := range in.Holidays {
+		if _, err := time.Parse(dateFmt, s); err != nil {
+			return nil, fmt.Errorf("bad holiday %q", s)
+		}
+		c.holidays[s] = true
+	}
+	for _, s := range in.Workdays {
+		if _, err := time.Parse(dateFmt, s); err != nil {
+			return nil, fmt.Errorf("bad workday %q", s)
+		}
+		c.workdays[s] = true
+	}
+	return c, nil
+}
+
+// ParseICS читает праздники из iCalendar-файла: каждое событие VEVENT
+// с DTSTART (и необязательным DTEND, не включительно) — нерабочие дни.
+// Правила повторения внутри файла не разворачиваются.
+func ParseICS(r io.Reader) (*Calendar, error) {
+	c := newCalendar()
+	var start, end time.Time
+	inEvent := false
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// DTSTART;VALUE=DATE:20250101 → имя без параметров
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent = true
+			start, end = time.Time{}, time.Time{}
+		case name == "END" && value == "VEVENT":
+			if !inEvent || start.IsZero() {
+				return nil, errors.New("event without DTSTART")
+			}
+			if end.IsZero() || !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+				c.holidays[d.Format(dateFmt)] = true
+			}
+			inEvent = false
+		case inEvent && (name == "DTSTART" || name == "DTEND"):
+			if len(value) < 8 {
+				return nil, fmt.Errorf("bad %s %q", name, value)
+			}
+			d, err := time.Parse(dateFmt, value[:8])
+			if err != nil {
+				return nil, fmt.Errorf("bad %s %q", name, value)
+			}
+			if name == "DTSTART" {
+				start = d
+			} else {
+				end = d
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newCalendar() *Calendar {
+	return &Calendar{
+		annual:   map[string]bool{},
+		holidays: map[string]bool{},
+		workdays: map[string]bool{},
+	}
+}
+
+// IsWorkday сообщает, рабочий ли день d: перенесённые рабочие дни — рабочие,
+// праздники — нет, иначе рабочими считаются понедельник..пятница.
+func (c *Calendar) IsWorkday(d time.Time) bool {
+	if c != nil {
+		key := d.Format(dateFmt)
+		if c.workdays[key] {
+			return true
+		}
+		if c.holidays[key] || c.annual[key[4:]] {
+			return false
+		}
+	}
+	wd := d.Weekday()
+	return wd != time.Saturday && wd != time.Sunday
+}
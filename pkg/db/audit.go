@@ -0,0 +1,116 @@
+// Package db: журнал изменений задач (таблица audit_log).
+package db
+
+import (
+	"sort"
+	"strings"
+)
+
+// AddAudit добавляет запись в журнал изменений.
+func (s *sqlStore) AddAudit(e *AuditEntry) error {
+	if e.Time == "" {
+		e.Time = timestamp()
+	}
+	if e.Diff == "" {
+		e.Diff = "{}"
+	}
+	return s.db.QueryRow(s.d.q(
+		`INSERT INTO audit_log (at, action, task_id, principal, remote, diff) VALUES (?, ?, ?, ?, ?, ?) RETURNING id`),
+		e.Time, e.Action, e.TaskID, e.Principal, e.Remote, e.Diff).Scan(&e.ID)
+}
+
+// Audit возвращает записи журнала под фильтр f.
+func (s *sqlStore) Audit(f AuditFilter) ([]*AuditEntry, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	if f.TaskID != 0 {
+		add("task_id = ?", f.TaskID)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.Principal != "" {
+		add("principal = ?", f.Principal)
+	}
+	if f.Since != "" {
+		add("at >= ?", f.Since)
+	}
+	if f.Until != "" {
+		add("at < ?", f.Until)
+	}
+	order := "id DESC"
+	if f.Asc {
+		add("id > ?", f.AfterID)
+		order = "id"
+	}
+	query := `SELECT id, at, action, task_id, principal, remote, diff FROM audit_log`
+	if len(where) > 0 {
+		query += "\n WHERE " + strings.Join(where, " AND ")
+	}
+	query += "\n ORDER BY " + order + "\n LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := s.db.Query(s.d.q(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*AuditEntry, 0)
+	for rows.Next() {
+		e := &AuditEntry{}
+		if err := rows.Scan(&e.ID, &e.Time, &e.Action, &e.TaskID, &e.Principal, &e.Remote, &e.Diff); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// AddAudit добавляет запись в журнал изменений.
+func (m *MemoryStore) AddAudit(e *AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e.Time == "" {
+		e.Time = timestamp()
+	}
+	if e.Diff == "" {
+		e.Diff = "{}"
+	}
+	e.ID = int64(len(m.audit) + 1)
+	rec := *e
+	m.audit = append(m.audit, &rec)
+	return nil
+}
+
+// Audit возвращает копии записей журнала под фильтр f.
+func (m *MemoryStore) Audit(f AuditFilter) ([]*AuditEntry, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	m.mu.Lock()
+	out := make([]*AuditEntry, 0)
+	for _, e := range m.audit {
+		if (f.TaskID == 0 || e.TaskID == f.TaskID) &&
+			(f.Action == "" || e.Action == f.Action) &&
+			(f.Principal == "" || e.Principal == f.Principal) &&
+			(f.Since == "" || e.Time >= f.Since) &&
+			(f.Until == "" || e.Time < f.Until) &&
+			(!f.Asc || e.ID > f.AfterID) {
+			rec := *e
+			out = append(out, &rec)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return (out[i].ID < out[j].ID) == f.Asc })
+	return out[:min(len(out), f.Limit)], nil
+}
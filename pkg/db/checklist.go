@@ -0,0 +1,135 @@
+// Package db: чек-листы задач (таблица task_items).
+package db
+
+import (
+	"database/sql"
+	"slices"
+)
+
+// replaceItems заменяет чек-лист задачи id внутри транзакции. Пункты
+// с ID, который уже был у этой задачи, сохраняют его, остальные получают новый.
+func (s *sqlStore) replaceItems(tx *sql.Tx, id int64, items []ChecklistItem) error {
+	rows, err := tx.Query(s.d.q(`SELECT id FROM task_items WHERE task_id = ?`), id)
+	if err != nil {
+		return err
+	}
+	var old []int64
+	for rows.Next() {
+		var n int64
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
+			return err
+		}
+		old = append(old, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(s.d.q(`DELETE FROM task_items WHERE task_id = ?`), id); err != nil {
+		return err
+	}
+	for pos, it := range items {
+		if i := slices.Index(old, it.ID); i >= 0 {
+			old[i] = 0 // повтор ID в items получит новый
+			_, err = tx.Exec(s.d.q(`INSERT INTO task_items (id, task_id, pos, title, done) VALUES (?, ?, ?, ?, ?)`),
+				it.ID, id, pos, it.Title, it.Done)
+		} else {
+			_, err = tx.Exec(s.d.q(`INSERT INTO task_items (task_id, pos, title, done) VALUES (?, ?, ?, ?)`),
+				id, pos, it.Title, it.Done)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checklist читает пункты чек-листа задачи id по порядку.
+func (s *sqlStore) checklist(id int64) ([]ChecklistItem, error) {
+	rows, err := s.db.Query(s.d.q(`SELECT id, title, done FROM task_items WHERE task_id = ? ORDER BY pos, id`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ChecklistItem
+	for rows.Next() {
+		var it ChecklistItem
+		if err := rows.Scan(&it.ID, &it.Title, &it.Done); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// CheckItem отмечает пункт item чек-листа задачи id выполненным или нет.
+func (s *sqlStore) CheckItem(id string, item int64, done bool) error {
+	n, err := taskID(id)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(s.d.q(`UPDATE task_items SET done = ? WHERE id = ? AND task_id = ?
+		AND task_id NOT IN (SELECT task_id FROM task_meta WHERE deleted <> '')`), done, item, n)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrItemNotFound
+	}
+	return nil
+}
+
+// setItemIDs назначает новые ID пунктам чек-листа задачи t, кроме тех,
+// что уже были у неё в old (вызывать под mu).
+func (m *MemoryStore) setItemIDs(t *Task, old []ChecklistItem) {
+	ids := make([]int64, 0, len(old))
+	for _, it := range old {
+		ids = append(ids, it.ID)
+	}
+	for i := range t.Checklist {
+		it := &t.Checklist[i]
+		if j := slices.Index(ids, it.ID); j >= 0 && it.ID != 0 {
+			ids[j] = 0 // повтор ID получит новый
+			continue
+		}
+		it.ID = m.nextItem
+		m.nextItem++
+	}
+}
+
+// listed — копия задачи для списков: без пунктов чек-листа, с Progress
+// и Blocked (вызывать под mu).
+func (m *MemoryStore) listed(t *Task) *Task {
+	c := copyTask(t)
+	c.Blocked = m.blocked(t)
+	done := 0
+	for _, it := range t.Checklist {
+		if it.Done {
+			done++
+		}
+	}
+	c.Progress = progress(done, len(t.Checklist))
+	c.Checklist = nil
+	return c
+}
+
+// CheckItem отмечает пункт item чек-листа задачи id выполненным или нет.
+func (m *MemoryStore) CheckItem(id string, item int64, done bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.lookup(id, false)
+	if err != nil {
+		return err
+	}
+	for i := range t.Checklist {
+		if t.Checklist[i].ID == item {
+			t.Checklist[i].Done = done
+			return nil
+		}
+	}
+	return ErrItemNotFound
+}
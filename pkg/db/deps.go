@@ -0,0 +1,210 @@
+// Package db: зависимости между задачами (таблица task_deps).
+package db
+
+import (
+	"database/sql"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parseIDs разбирает id задач из Task.BlockedBy: по возрастанию, без повторов.
+// Нечисловой id — ErrBlockerNotFound.
+func parseIDs(ids []string) ([]int64, error) {
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		n, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+		if err != nil {
+			return nil, ErrBlockerNotFound
+		}
+		out = append(out, n)
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+// formatIDs — id задач в виде Task.BlockedBy (nil для пустого списка).
+func formatIDs(ids []int64) []string {
+	var out []string
+	for _, n := range ids {
+		out = append(out, strconv.FormatInt(n, 10))
+	}
+	return out
+}
+
+// reachesTask — обход "от задачи к задачам, которые её блокируют": из blocker
+// достижима задача id, то есть зависимость id от blocker замкнёт цикл.
+const reachesTask = `WITH RECURSIVE up(id) AS (
+		SELECT CAST(? AS INTEGER)
+		UNION
+		SELECT d.blocker_id FROM task_deps d JOIN up ON d.task_id = up.id
+	)
+	SELECT count(*) FROM up WHERE id = ?`
+
+// replaceDeps заменяет зависимости задачи id внутри транзакции. Новая
+// блокирующая задача должна быть вне корзины, а зависимость — не замыкать
+// цикл; у оставшихся зависимостей сохраняется отметка done.
+func (s *sqlStore) replaceDeps(tx *sql.Tx, id int64, blockers []string) error {
+	ids, err := parseIDs(blockers)
+	if err != nil {
+		return err
+	}
+	done, err := s.depsDone(tx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.d.q(`DELETE FROM task_deps WHERE task_id = ?`), id); err != nil {
+		return err
+	}
+	for _, b := range ids {
+		if _, ok := done[b]; !ok {
+			var n int
+			err := tx.QueryRow(s.d.q(`SELECT count(*) FROM scheduler WHERE id = ? AND NOT `+inTrash), b).Scan(&n)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return ErrBlockerNotFound
+			}
+		}
+		var n int
+		if err := tx.QueryRow(s.d.q(reachesTask), b, id).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrDependencyCycle
+		}
+		_, err = tx.Exec(s.d.q(`INSERT INTO task_deps (task_id, blocker_id, done) VALUES (?, ?, ?)`), id, b, done[b])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// depsDone — текущие зависимости задачи id: блокирующая задача → done.
+func (s *sqlStore) depsDone(tx *sql.Tx, id int64) (map[int64]bool, error) {
+	rows, err := tx.Query(s.d.q(`SELECT blocker_id, done FROM task_deps WHERE task_id = ?`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int64]bool)
+	for rows.Next() {
+		var b int64
+		var ok bool
+		if err := rows.Scan(&b, &ok); err != nil {
+			return nil, err
+		}
+		done[b] = ok
+	}
+	return done, rows.Err()
+}
+
+// setDepsDone отмечает зависимости от задачи blocker выполненными (done)
+// или снова открытыми внутри транзакции.
+func (s *sqlStore) setDepsDone(tx *sql.Tx, blocker int64, done bool) error {
+	_, err := tx.Exec(s.d.q(`UPDATE task_deps SET done = ? WHERE blocker_id = ?`), done, blocker)
+	return err
+}
+
+// dep — зависимость задачи task от blocker в MemoryStore.
+type dep struct{ task, blocker int64 }
+
+// setDeps проверяет и нормализует зависимости задачи t так же, как
+// replaceDeps, и забывает отметки done снятых зависимостей (вызывать под mu,
+// пока t не сохранена).
+func (m *MemoryStore) setDeps(t *Task) error {
+	ids, err := parseIDs(t.BlockedBy)
+	if err != nil {
+		return err
+	}
+	var old []int64
+	if prev, ok := m.tasks[t.ID]; ok {
+		old, _ = parseIDs(prev.BlockedBy)
+	}
+	for _, b := range ids {
+		bt, ok := m.tasks[b]
+		if !ok || (bt.Deleted != "" && !slices.Contains(old, b)) {
+			return ErrBlockerNotFound
+		}
+		if m.reaches(b, t.ID) {
+			return ErrDependencyCycle
+		}
+	}
+	for _, b := range old {
+		if !slices.Contains(ids, b) {
+			delete(m.depsDone, dep{t.ID, b})
+		}
+	}
+	t.BlockedBy = formatIDs(ids)
+	return nil
+}
+
+// setDepsDone отмечает зависимости от задачи blocker выполненными (done)
+// или снова открытыми (вызывать под mu).
+func (m *MemoryStore) setDepsDone(blocker int64, done bool) {
+	s := strconv.FormatInt(blocker, 10)
+	for _, t := range m.tasks {
+		if !slices.Contains(t.BlockedBy, s) {
+			continue
+		}
+		if done {
+			m.depsDone[dep{t.ID, blocker}] = true
+		} else {
+			delete(m.depsDone, dep{t.ID, blocker})
+		}
+	}
+}
+
+// reaches — достижима ли задача id из from по зависимостям (вызывать под mu).
+func (m *MemoryStore) reaches(from, id int64) bool {
+	seen := map[int64]bool{}
+	stack := []int64{from}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n == id {
+			return true
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		if t, ok := m.tasks[n]; ok {
+			ids, _ := parseIDs(t.BlockedBy)
+			stack = append(stack, ids...)
+		}
+	}
+	return false
+}
+
+// blocked — есть ли у задачи t зависимости, ещё не отмеченные выполненными
+// (вызывать под mu).
+func (m *MemoryStore) blocked(t *Task) bool {
+	ids, _ := parseIDs(t.BlockedBy)
+	for _, b := range ids {
+		if !m.depsDone[dep{t.ID, b}] {
+			return true
+		}
+	}
+	return false
+}
+
+// dropDeps убирает окончательно удалённую задачу id из зависимостей
+// остальных вместе с отметками done (как ON DELETE CASCADE; вызывать под mu).
+func (m *MemoryStore) dropDeps(id int64) {
+	s := strconv.FormatInt(id, 10)
+	for _, t := range m.tasks {
+		t.BlockedBy = slices.DeleteFunc(t.BlockedBy, func(b string) bool { return b == s })
+		if len(t.BlockedBy) == 0 {
+			t.BlockedBy = nil
+		}
+	}
+	for d := range m.depsDone {
+		if d.task == id || d.blocker == id {
+			delete(m.depsDone, d)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package db: различия диалектов SQL между SQLite и PostgreSQL.
+// Запросы пишутся один раз в синтаксисе SQLite с плейсхолдерами "?",
+// а dialect переписывает то немногое, что PostgreSQL понимает иначе.
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// dialect — особенности конкретной СУБД.
+type dialect struct {
+	name     string
+	numbered bool // плейсхолдеры $1, $2, ... вместо ?
+	// ddl — замены в DDL миграций (тип автоинкрементного ключа и т.п.)
+	ddl *strings.Replacer
+	// hasColumn — запрос "есть ли колонка" с параметрами (таблица, колонка)
+	hasColumn string
+	// bytewise — суффикс, с которым строки сравниваются побайтно, как в SQLite
+	// и в Go (иначе PostgreSQL упорядочивает их по правилам локали)
+	bytewise string
+	// ftsJoin, ftsWhere и ftsOrder — части запроса полнотекстового поиска;
+	// единственный параметр (в ftsJoin или ftsWhere) — выражение из ftsTerm,
+	// соединённых ftsAnd. ftsExclude — условие "не совпадает" с таким же параметром.
+	ftsJoin, ftsWhere, ftsOrder string
+	ftsExclude                  string
+	ftsAnd                      string
+	// ftsTerm — выражение для слов условия: по началу слов или точная фраза
+	// (слова состоят только из букв и цифр, экранирование не нужно)
+	ftsTerm func(words []string, exact bool) string
+}
+
+var (
+	sqliteDialect = &dialect{
+		name:      "sqlite",
+		ddl:       strings.NewReplacer(),
+		hasColumn: `SELECT count(*) FROM pragma_table_info(?) WHERE name = ?`,
+		// bm25 тем меньше, чем релевантнее; совпадение в title весит вдвое больше
+		ftsJoin:    ` JOIN scheduler_fts ON scheduler_fts.rowid = s.id`,
+		ftsWhere:   `scheduler_fts MATCH ?`,
+		ftsOrder:   `bm25(scheduler_fts, 2.0, 1.0)`,
+		ftsExclude: `s.id NOT IN (SELECT rowid FROM scheduler_fts WHERE scheduler_fts MATCH ?)`,
+		ftsAnd:     " ",
+		ftsTerm: func(words []string, exact bool) string {
+			// "слово"* — поиск по префиксу, "слово слово" — фраза
+			if exact {
+				return `"` + strings.Join(words, " ") + `"`
+			}
+			q := make([]string, len(words))
+			for i, w := range words {
+				q[i] = `"` + w + `"*`
+			}
+			return strings.Join(q, " ")
+		},
+	}
+	// В PostgreSQL CHAR(n) дополняет значения пробелами, поэтому даты
+	// хранятся в VARCHAR(8): пустое until должно читаться как "".
+	postgresDialect = &dialect{
+		name:     "postgres",
+		numbered: true,
+		ddl: strings.NewReplacer(
+			"INTEGER PRIMARY KEY AUTOINCREMENT", "SERIAL PRIMARY KEY",
+			" CHAR(8)", " VARCHAR(8)",
+		),
+		hasColumn: `SELECT count(*) FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name::text = ? AND column_name::text = ?`,
+		bytewise:   ` COLLATE "C"`,
+		ftsJoin:    ` CROSS JOIN to_tsquery('simple', ?) AS fts_q`,
+		ftsWhere:   `s.search @@ fts_q`,
+		ftsOrder:   `ts_rank(s.search, fts_q) DESC`,
+		ftsExclude: `NOT (s.search @@ to_tsquery('simple', ?))`,
+		ftsAnd:     " & ",
+		ftsTerm: func(words []string, exact bool) string {
+			// слово:* — поиск по префиксу, (слово <-> слово) — фраза
+			if exact {
+				return "(" + strings.Join(words, " <-> ") + ")"
+			}
+			return "(" + strings.Join(words, ":* & ") + ":*)"
+		},
+	}
+)
+
+// dialectOf определяет диалект по драйверу открытого соединения.
+func dialectOf(d *sql.DB) *dialect {
+	if _, ok := d.Driver().(*stdlib.Driver); ok {
+		return postgresDialect
+	}
+	return sqliteDialect
+}
+
+// q переписывает плейсхолдеры "?" запроса под диалект.
+// В запросах пакета "?" не встречается внутри строковых литералов.
+func (d *dialect) q(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
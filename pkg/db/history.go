@@ -0,0 +1,116 @@
+// Package db: история выполнения задач (таблица task_history).
+package db
+
+import (
+	"sort"
+	"strings"
+)
+
+// AddCompletion добавляет запись в историю выполнения и в той же
+// транзакции отмечает выполненными зависимости от задачи c.TaskID.
+func (s *sqlStore) AddCompletion(c *Completion) error {
+	if c.Completed == "" {
+		c.Completed = timestamp()
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(s.d.q(
+		`INSERT INTO task_history (task_id, title, date, completed) VALUES (?, ?, ?, ?) RETURNING id`),
+		c.TaskID, c.Title, c.Date, c.Completed).Scan(&c.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.setDepsDone(tx, c.TaskID, true); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// History возвращает записи истории под фильтр f, сначала последние.
+func (s *sqlStore) History(f HistoryFilter) ([]*Completion, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	var where []string
+	var args []any
+	if f.TaskID != 0 {
+		where = append(where, "task_id = ?")
+		args = append(args, f.TaskID)
+	}
+	if f.Since != "" {
+		where = append(where, "completed >= ?")
+		args = append(args, f.Since)
+	}
+	if f.Until != "" {
+		where = append(where, "completed < ?")
+		args = append(args, f.Until)
+	}
+	query := `SELECT id, task_id, title, date, completed FROM task_history`
+	if len(where) > 0 {
+		query += "\n WHERE " + strings.Join(where, " AND ")
+	}
+	query += "\n ORDER BY completed DESC, id DESC\n LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := s.db.Query(s.d.q(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*Completion, 0)
+	for rows.Next() {
+		c := &Completion{}
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.Title, &c.Date, &c.Completed); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// AddCompletion добавляет запись в историю выполнения и отмечает
+// выполненными зависимости от задачи c.TaskID.
+func (m *MemoryStore) AddCompletion(c *Completion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c.Completed == "" {
+		c.Completed = timestamp()
+	}
+	c.ID = int64(len(m.history) + 1)
+	rec := *c
+	m.history = append(m.history, &rec)
+	m.setDepsDone(c.TaskID, true)
+	return nil
+}
+
+// History возвращает копии записей истории под фильтр f, сначала последние.
+func (m *MemoryStore) History(f HistoryFilter) ([]*Completion, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	m.mu.Lock()
+	out := make([]*Completion, 0)
+	for _, c := range m.history {
+		if (f.TaskID == 0 || c.TaskID == f.TaskID) &&
+			(f.Since == "" || c.Completed >= f.Since) &&
+			(f.Until == "" || c.Completed < f.Until) {
+			rec := *c
+			out = append(out, &rec)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Completed != out[j].Completed {
+			return out[i].Completed > out[j].Completed
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out[:min(len(out), f.Limit)], nil
+}
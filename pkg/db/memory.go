@@ -0,0 +1,400 @@
+// Package db: реализация TaskStore в памяти процесса.
+package db
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MemoryStore — хранилище задач в памяти. Подходит для тестов и временных
+// запусков: данные живут, пока жив процесс. Безопасно для конкурентного доступа;
+// наружу всегда отдаются копии задач.
+type MemoryStore struct {
+	mu      sync.Mutex
+	tasks   map[int64]*Task
+	nextID  int64
+	history []*Completion
+	audit   []*AuditEntry
+	tags    map[string]bool // все метки, в том числе ни у одной задачи
+
+	depsDone map[dep]bool // выполненные зависимости (task_deps.done)
+
+	projects    map[int64]*Project
+	nextProject int64
+	nextItem    int64 // следующий ID пункта чек-листа
+}
+
+// NewMemoryStore создаёт пустое хранилище в памяти (с проектом DefaultProject).
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tasks: make(map[int64]*Task), nextID: 1, tags: make(map[string]bool),
+		depsDone:    make(map[dep]bool),
+		projects:    map[int64]*Project{DefaultProject: {ID: DefaultProject, Name: "Входящие"}},
+		nextProject: DefaultProject + 1,
+		nextItem:    1,
+	}
+}
+
+// copyTask — независимая копия задачи (вместе со срезами исключений, меток,
+// чек-листом и зависимостями).
+func copyTask(t *Task) *Task {
+	c := *t
+	c.Exdates = sortedUnique(t.Exdates)
+	c.Tags = sortedUnique(t.Tags)
+	c.Checklist = slices.Clone(t.Checklist)
+	c.BlockedBy = slices.Clone(t.BlockedBy)
+	return &c
+}
+
+// addTags запоминает метки задачи t (вызывать под mu).
+func (m *MemoryStore) addTags(t *Task) {
+	for _, name := range t.Tags {
+		m.tags[name] = true
+	}
+}
+
+// lookup находит задачу по строковому идентификатору (вызывать под mu):
+// trashed — искать в корзине (иначе — среди задач вне её).
+func (m *MemoryStore) lookup(id string, trashed bool) (*Task, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	t, ok := m.tasks[n]
+	if !ok || (t.Deleted != "") != trashed {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+// AddTask сохраняет копию задачи под новым идентификатором.
+func (m *MemoryStore) AddTask(task *Task) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := copyTask(task)
+	t.ID = m.nextID
+	if err := m.setDeps(t); err != nil {
+		return 0, err
+	}
+	t.Created = createdAt(task)
+	t.Deleted = ""
+	t.ProjectID = projectOf(task)
+	t.Progress = ""
+	t.Blocked = false
+	m.setItemIDs(t, nil)
+	m.addTags(t)
+	m.nextID++
+	m.tasks[t.ID] = t
+	return t.ID, nil
+}
+
+// Tasks повторяет поведение SQLiteStore.Tasks: фильтры q, сортировка по ключу
+// p.Sort (при равных значениях — по id) с курсором после последней задачи.
+// При поиске без p.Sort — сначала самые релевантные: релевантность — число
+// совпавших слов, слова title весят вдвое; курсор — смещение.
+func (m *MemoryStore) Tasks(q Query, p Page) (*TaskPage, error) {
+	if p.Limit <= 0 {
+		p.Limit = 50
+	}
+	hl := highlightWords(q.Include)
+	relevance := p.Sort == "" && len(q.Include) > 0
+	if !relevance && p.Sort == "" {
+		p.Sort = SortDate
+	}
+
+	type scored struct {
+		t     *Task
+		score int
+	}
+	var found []scored
+	m.mu.Lock()
+	for _, t := range m.tasks {
+		if t.Deleted != "" || !memoryHasTags(t, q) || !m.inProject(t, q.Project) {
+			continue
+		}
+		if n, ok := memoryMatch(t, q, hl); ok {
+			found = append(found, scored{m.listed(t), n})
+		}
+	}
+	m.mu.Unlock()
+	page := &TaskPage{Tasks: make([]*Task, 0, min(len(found), p.Limit))}
+	if p.Total {
+		page.Total = len(found)
+	}
+
+	// cmp < 0 — задача со значением ключа va и id ida раньше vb, idb в выдаче
+	cmp := func(va string, ida int64, vb string, idb int64) int {
+		c := strings.Compare(va, vb)
+		if c == 0 {
+			c = int(ida - idb)
+		}
+		if p.Desc {
+			c = -c
+		}
+		return c
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if relevance {
+			if a.score != b.score {
+				return a.score > b.score
+			}
+			if a.t.Date != b.t.Date {
+				return a.t.Date < b.t.Date
+			}
+			return a.t.ID < b.t.ID
+		}
+		return cmp(sortValue(a.t, p.Sort), a.t.ID, sortValue(b.t, p.Sort), b.t.ID) < 0
+	})
+
+	start := 0
+	if p.After != nil {
+		if relevance {
+			start = min(p.After.Offset, len(found))
+		} else {
+			for start < len(found) && cmp(sortValue(found[start].t, p.Sort), found[start].t.ID, p.After.Value, p.After.ID) <= 0 {
+				start++
+			}
+		}
+	}
+	for _, f := range found[start:min(len(found), start+p.Limit)] {
+		if len(q.Include) > 0 {
+			f.t.Snippet = snippet(f.t, hl)
+		}
+		page.Tasks = append(page.Tasks, f.t)
+	}
+	if start+p.Limit < len(found) {
+		page.Next = nextCursor(page.Tasks, p, relevance)
+	}
+	return page, nil
+}
+
+// memoryMatch проверяет задачу t по запросу q и возвращает её релевантность
+// для слов hl.
+func memoryMatch(t *Task, q Query, hl []string) (int, bool) {
+	switch {
+	case q.From != "" && t.Date < q.From,
+		q.To != "" && t.Date > q.To,
+		q.Repeat == RepeatYes && t.Repeat == "",
+		q.Repeat == RepeatNo && t.Repeat != "":
+		return 0, false
+	}
+	title, comment := searchTerms(t.Title), searchTerms(t.Comment)
+	for _, term := range q.Include {
+		if !matchTerm(title, comment, term) {
+			return 0, false
+		}
+	}
+	for _, term := range q.Exclude {
+		if matchTerm(title, comment, term) {
+			return 0, false
+		}
+	}
+	return 2*countMatches(title, hl) + countMatches(comment, hl), true
+}
+
+// GetTask возвращает копию задачи или ErrNotFound.
+func (m *MemoryStore) GetTask(id string) (*Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.lookup(id, false)
+	if err != nil {
+		return nil, err
+	}
+	c := m.listed(t)
+	c.Checklist = slices.Clone(t.Checklist)
+	return c, nil
+}
+
+// UpdateTask перезаписывает задачу, сохраняя счётчик выполненных повторений.
+func (m *MemoryStore) UpdateTask(task *Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.tasks[task.ID]
+	if !ok || old.Deleted != "" {
+		return fmt.Errorf("incorrect id for updating task")
+	}
+	t := copyTask(task)
+	if err := m.setDeps(t); err != nil {
+		return err
+	}
+	t.RepeatDone = old.RepeatDone
+	t.Created = old.Created
+	t.Deleted = old.Deleted
+	t.ProjectID = projectOf(task)
+	t.Progress = ""
+	t.Blocked = false
+	m.setItemIDs(t, old.Checklist)
+	m.addTags(t)
+	m.tasks[t.ID] = t
+	return nil
+}
+
+// DeleteTask переносит задачу в корзину.
+func (m *MemoryStore) DeleteTask(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.lookup(id, false)
+	if err != nil {
+		return err
+	}
+	t.Deleted = timestamp()
+	return nil
+}
+
+// Trash возвращает копии задач из корзины, недавно удалённые первыми.
+func (m *MemoryStore) Trash() ([]*Task, error) {
+	m.mu.Lock()
+	out := make([]*Task, 0)
+	for _, t := range m.tasks {
+		if t.Deleted != "" {
+			out = append(out, m.listed(t))
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Deleted != out[j].Deleted {
+			return out[i].Deleted > out[j].Deleted
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out, nil
+}
+
+// RestoreTask возвращает задачу из корзины; зависящие от неё задачи
+// снова ждут её выполнения.
+func (m *MemoryStore) RestoreTask(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.lookup(id, true)
+	if err != nil {
+		return err
+	}
+	t.Deleted = ""
+	m.setDepsDone(t.ID, false)
+	return nil
+}
+
+// PurgeTask окончательно удаляет задачу из корзины.
+func (m *MemoryStore) PurgeTask(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.lookup(id, true)
+	if err != nil {
+		return err
+	}
+	delete(m.tasks, t.ID)
+	m.dropDeps(t.ID)
+	return nil
+}
+
+// PurgeDeleted окончательно удаляет задачи, попавшие в корзину раньше before.
+func (m *MemoryStore) PurgeDeleted(before string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for id, t := range m.tasks {
+		if t.Deleted != "" && t.Deleted < before {
+			delete(m.tasks, id)
+			m.dropDeps(id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// UpdateDate переносит задачу на дату next (scheduled — дата по расписанию).
+func (m *MemoryStore) UpdateDate(next, scheduled string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.lookup(id, false)
+	if err != nil {
+		return err
+	}
+	t.Date, t.Scheduled = next, scheduled
+	return nil
+}
+
+// CompleteOccurrence переносит задачу на next и снимает отметки с чек-листа;
+// счётчик выполненных повторений ведётся, только если у задачи есть
+// настройки серии (в SQLite он хранится в строке task_repeat).
+func (m *MemoryStore) CompleteOccurrence(next, scheduled string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.lookup(id, false)
+	if err != nil {
+		return err
+	}
+	t.Date, t.Scheduled = next, scheduled
+	if t.hasRepeatSettings() {
+		t.RepeatDone++
+	}
+	for i := range t.Checklist {
+		t.Checklist[i].Done = false
+	}
+	return nil
+}
+
+// AddExdate добавляет дату-исключение (повтор не ошибка).
+func (m *MemoryStore) AddExdate(id string, date string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.lookup(id, false)
+	if err != nil {
+		return err
+	}
+	t.Exdates = sortedUnique(append(t.Exdates, date))
+	return nil
+}
+
+// DeleteExdate убирает дату-исключение или возвращает "exdate not found".
+func (m *MemoryStore) DeleteExdate(id string, date string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.lookup(id, false)
+	if err != nil {
+		return err
+	}
+	for i, d := range t.Exdates {
+		if d == date {
+			t.Exdates = append(t.Exdates[:i:i], t.Exdates[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("exdate not found")
+}
+
+// SkipOccurrence добавляет дату-исключение и переносит задачу на next.
+func (m *MemoryStore) SkipOccurrence(date, next, scheduled string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.lookup(id, false)
+	if err != nil {
+		return err
+	}
+	t.Exdates = sortedUnique(append(t.Exdates, date))
+	t.Date, t.Scheduled = next, scheduled
+	return nil
+}
+
+// Close ничего не делает: ресурсов, требующих освобождения, нет.
+func (m *MemoryStore) Close() error {
+	return nil
+}
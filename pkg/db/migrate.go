@@ -0,0 +1,329 @@
+// Package db: версионные миграции схемы.
+// Каждая миграция — шаг "вверх" с номером версии; применённые версии
+// записываются в таблицу schema_migrations. Миграции применяются по порядку,
+// каждая в своей транзакции вместе с отметкой о применении.
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration — один шаг изменения схемы.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx, d *dialect) error
+}
+
+// MigrationState — миграция и сведения о её применении к конкретной БД.
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt string
+}
+
+// migrations — все миграции по возрастанию версии. Добавлять только в конец,
+// уже выпущенные шаги не менять.
+//
+// Схема:
+//   - scheduler    — задачи: id, date CHAR(8) (20060102), title, comment,
+//     repeat VARCHAR(128) (правило повторения, формат описан в api);
+//   - task_repeat  — настройки серии (одна строка на задачу, только если заданы):
+//     until CHAR(8), max_count, done_count, repeat_from (пусто или 'done');
+//   - task_exdates — даты-исключения серии (EXDATE);
+//   - scheduler_fts — полнотекстовый индекс title/comment (FTS5, синхронизируется
+//     триггерами); в PostgreSQL вместо него — колонка scheduler.search (tsvector);
+//   - task_meta    — служебные поля задачи: created (время создания, RFC 3339)
+//     и deleted (когда перенесена в корзину, пусто — не в корзине);
+//     у задач, созданных до миграции 6, строки может не быть;
+//   - task_history — история выполнения: task_id, title и date на момент
+//     выполнения, completed (RFC 3339); переживает удаление задачи;
+//   - audit_log    — журнал изменений задач: время, действие, task_id, кто
+//     и откуда, diff (JSON изменившихся полей);
+//   - tags, task_tags — метки (уникальные имена) и их связь с задачами;
+//   - projects     — проекты: name (уникальное), color, archived; первый
+//     (id 1, «Входящие») создаёт миграция, task_meta.project_id ссылается
+//     на проект, у задач без строки task_meta проект — 1;
+//     task_meta.priority — номер приоритета задачи (0 — без приоритета);
+//     task_meta.scheduled — дата повторения до переноса на рабочий день;
+//   - task_items   — чек-листы: пункты задачи (pos — порядок, title, done);
+//   - task_deps    — зависимости: задачу task_id нельзя начинать, пока не
+//     выполнена blocker_id (граф без циклов); done — blocker_id выполнена
+//     после появления зависимости.
+//
+// Первые шаги написаны идемпотентно (IF NOT EXISTS / проверка колонки):
+// до появления миграций эти таблицы создавались при каждом запуске,
+// и в старых файлах часть из них уже есть. DDL пишется для SQLite;
+// для PostgreSQL типы подменяет dialect.ddl.
+var migrations = []Migration{
+	{1, "create scheduler", execSQL(`
+		CREATE TABLE IF NOT EXISTS scheduler (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date CHAR(8) NOT NULL DEFAULT '',
+			title VARCHAR(255) NOT NULL DEFAULT '',
+			comment TEXT NOT NULL DEFAULT '',
+			repeat VARCHAR(128) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_scheduler_date ON scheduler(date);`)},
+	{2, "create task_repeat", execSQL(`
+		CREATE TABLE IF NOT EXISTS task_repeat (
+			task_id INTEGER PRIMARY KEY REFERENCES scheduler(id) ON DELETE CASCADE,
+			until CHAR(8) NOT NULL DEFAULT '',
+			max_count INTEGER NOT NULL DEFAULT 0,
+			done_count INTEGER NOT NULL DEFAULT 0
+		);`)},
+	{3, "create task_exdates", execSQL(`
+		CREATE TABLE IF NOT EXISTS task_exdates (
+			task_id INTEGER NOT NULL REFERENCES scheduler(id) ON DELETE CASCADE,
+			date CHAR(8) NOT NULL,
+			PRIMARY KEY (task_id, date)
+		);`)},
+	{4, "add task_repeat.repeat_from", addColumn("task_repeat", "repeat_from", "TEXT NOT NULL DEFAULT ''")},
+	{5, "create full-text index", createFTS},
+	{6, "create task_meta", execSQL(`
+		CREATE TABLE IF NOT EXISTS task_meta (
+			task_id INTEGER PRIMARY KEY REFERENCES scheduler(id) ON DELETE CASCADE,
+			created VARCHAR(32) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_task_meta_created ON task_meta(created);`)},
+	{7, "add task_meta.deleted", addColumn("task_meta", "deleted", "VARCHAR(32) NOT NULL DEFAULT ''")},
+	{8, "create task_history", execSQL(`
+		CREATE TABLE IF NOT EXISTS task_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			title VARCHAR(255) NOT NULL DEFAULT '',
+			date CHAR(8) NOT NULL DEFAULT '',
+			completed VARCHAR(32) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_task_history_completed ON task_history(completed);
+		CREATE INDEX IF NOT EXISTS idx_task_history_task ON task_history(task_id, completed);`)},
+	{9, "create audit_log", execSQL(`
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			at VARCHAR(32) NOT NULL DEFAULT '',
+			action VARCHAR(32) NOT NULL DEFAULT '',
+			task_id INTEGER NOT NULL DEFAULT 0,
+			principal VARCHAR(255) NOT NULL DEFAULT '',
+			remote VARCHAR(64) NOT NULL DEFAULT '',
+			diff TEXT NOT NULL DEFAULT '{}'
+		);
+		CREATE INDEX IF NOT EXISTS idx_audit_log_task ON audit_log(task_id);
+		CREATE INDEX IF NOT EXISTS idx_audit_log_at ON audit_log(at);`)},
+	{10, "create tags", execSQL(`
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(64) NOT NULL UNIQUE
+		);
+		CREATE TABLE IF NOT EXISTS task_tags (
+			task_id INTEGER NOT NULL REFERENCES scheduler(id) ON DELETE CASCADE,
+			tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+			PRIMARY KEY (task_id, tag_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_task_tags_tag ON task_tags(tag_id);`)},
+	{11, "create projects", execSQL(`
+		CREATE TABLE IF NOT EXISTS projects (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(128) NOT NULL UNIQUE,
+			color VARCHAR(16) NOT NULL DEFAULT '',
+			archived BOOLEAN NOT NULL DEFAULT FALSE
+		);
+		INSERT INTO projects (name) SELECT 'Входящие' WHERE NOT EXISTS (SELECT 1 FROM projects);`)},
+	{12, "add task_meta.project_id", addColumn("task_meta", "project_id", "INTEGER NOT NULL DEFAULT 1")},
+	{13, "add task_meta.priority", addColumn("task_meta", "priority", "INTEGER NOT NULL DEFAULT 0")},
+	{14, "create task_items", execSQL(`
+		CREATE TABLE IF NOT EXISTS task_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL REFERENCES scheduler(id) ON DELETE CASCADE,
+			pos INTEGER NOT NULL DEFAULT 0,
+			title VARCHAR(255) NOT NULL DEFAULT '',
+			done BOOLEAN NOT NULL DEFAULT FALSE
+		);
+		CREATE INDEX IF NOT EXISTS idx_task_items_task ON task_items(task_id, pos);`)},
+	{15, "create task_deps", execSQL(`
+		CREATE TABLE IF NOT EXISTS task_deps (
+			task_id INTEGER NOT NULL REFERENCES scheduler(id) ON DELETE CASCADE,
+			blocker_id INTEGER NOT NULL REFERENCES scheduler(id) ON DELETE CASCADE,
+			PRIMARY KEY (task_id, blocker_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_task_deps_blocker ON task_deps(blocker_id);`)},
+	{16, "add task_meta.scheduled", addColumn("task_meta", "scheduled", "CHAR(8) NOT NULL DEFAULT ''")},
+	{17, "add task_deps.done", addDepsDone},
+}
+
+// addDepsDone добавляет отметку task_deps.done. До неё выполненной
+// считалась блокирующая задача из корзины — такие зависимости и отмечаются.
+func addDepsDone(tx *sql.Tx, d *dialect) error {
+	if err := addColumn("task_deps", "done", "BOOLEAN NOT NULL DEFAULT FALSE")(tx, d); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE task_deps SET done = TRUE
+		WHERE blocker_id IN (SELECT task_id FROM task_meta WHERE deleted <> '')`)
+	return err
+}
+
+// createFTS — полнотекстовый индекс по title и comment. В SQLite — внешняя
+// FTS5-таблица над scheduler (unicode61 приводит регистр любых букв), которую
+// поддерживают триггеры; уже существующие задачи индексируются командой rebuild.
+// В PostgreSQL — вычисляемая колонка tsvector с GIN-индексом (title — вес A).
+func createFTS(tx *sql.Tx, d *dialect) error {
+	q := `
+		CREATE VIRTUAL TABLE IF NOT EXISTS scheduler_fts USING fts5(
+			title, comment,
+			content='scheduler', content_rowid='id',
+			tokenize='unicode61 remove_diacritics 0'
+		);
+		CREATE TRIGGER IF NOT EXISTS scheduler_fts_insert AFTER INSERT ON scheduler BEGIN
+			INSERT INTO scheduler_fts (rowid, title, comment) VALUES (new.id, new.title, new.comment);
+		END;
+		CREATE TRIGGER IF NOT EXISTS scheduler_fts_delete AFTER DELETE ON scheduler BEGIN
+			INSERT INTO scheduler_fts (scheduler_fts, rowid, title, comment)
+			VALUES ('delete', old.id, old.title, old.comment);
+		END;
+		CREATE TRIGGER IF NOT EXISTS scheduler_fts_update AFTER UPDATE OF title, comment ON scheduler BEGIN
+			INSERT INTO scheduler_fts (scheduler_fts, rowid, title, comment)
+			VALUES ('delete', old.id, old.title, old.comment);
+			INSERT INTO scheduler_fts (rowid, title, comment) VALUES (new.id, new.title, new.comment);
+		END;
+		INSERT INTO scheduler_fts (scheduler_fts) VALUES ('rebuild');`
+	if d == postgresDialect {
+		q = `
+		ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS search tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', title), 'A') ||
+				setweight(to_tsvector('simple', comment), 'B')
+			) STORED;
+		CREATE INDEX IF NOT EXISTS idx_scheduler_search ON scheduler USING GIN (search);`
+	}
+	_, err := tx.Exec(q)
+	return err
+}
+
+// execSQL — миграция из набора SQL-команд.
+func execSQL(q string) func(tx *sql.Tx, d *dialect) error {
+	return func(tx *sql.Tx, d *dialect) error {
+		_, err := tx.Exec(d.ddl.Replace(q))
+		return err
+	}
+}
+
+// addColumn — миграция, добавляющая колонку, если её ещё нет.
+func addColumn(table, name, def string) func(tx *sql.Tx, d *dialect) error {
+	return func(tx *sql.Tx, d *dialect) error {
+		var n int
+		err := tx.QueryRow(d.q(d.hasColumn), table, name).Scan(&n)
+		if err != nil || n > 0 {
+			return err
+		}
+		_, err = tx.Exec(d.ddl.Replace(`ALTER TABLE ` + table + ` ADD COLUMN ` + name + ` ` + def))
+		return err
+	}
+}
+
+// LatestVersion — номер последней известной миграции.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// ensureMigrationsTable создаёт служебную таблицу версий.
+func ensureMigrationsTable(d *sql.DB) error {
+	_, err := d.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT '',
+		applied_at TEXT NOT NULL DEFAULT ''
+	)`)
+	return err
+}
+
+// MigrationStatus возвращает все миграции с отметкой, применены ли они к БД d.
+func MigrationStatus(d *sql.DB) ([]MigrationState, error) {
+	if err := ensureMigrationsTable(d); err != nil {
+		return nil, err
+	}
+	rows, err := d.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var v int
+		var at string
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		at, ok := applied[m.Version]
+		out = append(out, MigrationState{Migration: m, Applied: ok, AppliedAt: at})
+	}
+	return out, nil
+}
+
+// CurrentVersion — максимальная применённая версия (0 — пустая БД).
+func CurrentVersion(d *sql.DB) (int, error) {
+	if err := ensureMigrationsTable(d); err != nil {
+		return 0, err
+	}
+	var v int
+	err := d.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	return v, err
+}
+
+// Migrate применяет недостающие миграции до версии target включительно
+// (target <= 0 — до последней). Откат на более раннюю версию не поддерживается.
+// Диалект (SQLite или PostgreSQL) определяется по драйверу соединения d.
+func Migrate(d *sql.DB, target int) error {
+	if target <= 0 {
+		target = LatestVersion()
+	}
+	if target > LatestVersion() {
+		return fmt.Errorf("unknown schema version %d (latest %d)", target, LatestVersion())
+	}
+	cur, err := CurrentVersion(d)
+	if err != nil {
+		return err
+	}
+	if target < cur {
+		return fmt.Errorf("schema version %d is newer than %d: downgrade is not supported", cur, target)
+	}
+
+	for _, m := range migrations {
+		if m.Version <= cur || m.Version > target {
+			continue
+		}
+		if err := applyMigration(d, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration выполняет одну миграцию и отмечает её в одной транзакции.
+func applyMigration(d *sql.DB, m Migration) error {
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	dl := dialectOf(d)
+	if err := m.Up(tx, dl); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		dl.q(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,269 @@
+// Package db: проекты — отдельные списки задач (таблица projects).
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// taskProject — проект задачи в запросах с fromTasks (1 — DefaultProject
+// для задач без строки task_meta); archivedProjects — id архивных проектов.
+const (
+	taskProject      = `COALESCE(m.project_id, 1)`
+	archivedProjects = `(SELECT id FROM projects WHERE archived)`
+)
+
+// selectProjects — проекты с числом задач вне корзины.
+const selectProjects = `SELECT p.id, p.name, p.color, p.archived,
+	(SELECT count(*) FROM scheduler s LEFT JOIN task_meta m ON m.task_id = s.id
+		WHERE ` + taskProject + ` = p.id AND ` + notTrashed + `)
+	FROM projects p`
+
+// projectID разбирает строковый идентификатор проекта (как taskID).
+func projectID(id string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+	if err != nil {
+		return 0, ErrProjectNotFound
+	}
+	return n, nil
+}
+
+// scanProject читает одну строку, полученную запросом selectProjects.
+func scanProject(row scanner) (*Project, error) {
+	p := &Project{}
+	err := row.Scan(&p.ID, &p.Name, &p.Color, &p.Archived, &p.Tasks)
+	return p, err
+}
+
+// Projects возвращает все проекты по возрастанию id.
+func (s *sqlStore) Projects() ([]*Project, error) {
+	rows, err := s.db.Query(selectProjects + ` ORDER BY p.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*Project, 0)
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// GetProject возвращает проект по идентификатору или ErrProjectNotFound.
+func (s *sqlStore) GetProject(id string) (*Project, error) {
+	n, err := projectID(id)
+	if err != nil {
+		return nil, err
+	}
+	p, err := scanProject(s.db.QueryRow(s.d.q(selectProjects+` WHERE p.id = ?`), n))
+	if err == sql.ErrNoRows {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// nameTaken — есть ли другой (с id не равным id) проект с именем name.
+func (s *sqlStore) nameTaken(name string, id int64) (bool, error) {
+	var n int
+	err := s.db.QueryRow(s.d.q(`SELECT count(*) FROM projects WHERE name = ? AND id <> ?`), name, id).Scan(&n)
+	return n > 0, err
+}
+
+// AddProject создаёт проект и возвращает его идентификатор.
+func (s *sqlStore) AddProject(p *Project) (int64, error) {
+	taken, err := s.nameTaken(p.Name, 0)
+	if err != nil {
+		return 0, err
+	}
+	if taken {
+		return 0, ErrProjectExists
+	}
+	var id int64
+	err = s.db.QueryRow(s.d.q(`INSERT INTO projects (name, color, archived) VALUES (?, ?, ?) RETURNING id`),
+		p.Name, p.Color, p.Archived).Scan(&id)
+	return id, err
+}
+
+// UpdateProject перезаписывает имя, цвет и признак архива проекта.
+func (s *sqlStore) UpdateProject(p *Project) error {
+	if p.ID == DefaultProject && p.Archived {
+		return ErrDefaultProject
+	}
+	taken, err := s.nameTaken(p.Name, p.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrProjectExists
+	}
+	res, err := s.db.Exec(s.d.q(`UPDATE projects SET name = ?, color = ?, archived = ? WHERE id = ?`),
+		p.Name, p.Color, p.Archived, p.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrProjectNotFound
+	}
+	return nil
+}
+
+// DeleteProject удаляет проект, перенося его задачи в DefaultProject.
+func (s *sqlStore) DeleteProject(id string) error {
+	n, err := projectID(id)
+	if err != nil {
+		return err
+	}
+	if n == DefaultProject {
+		return ErrDefaultProject
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(s.d.q(`DELETE FROM projects WHERE id = ?`), n)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrProjectNotFound
+	}
+	_, err = tx.Exec(s.d.q(`UPDATE task_meta SET project_id = ? WHERE project_id = ?`), DefaultProject, n)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// projectTasks — число задач вне корзины в проекте id (вызывать под mu).
+func (m *MemoryStore) projectTasks(id int64) int {
+	n := 0
+	for _, t := range m.tasks {
+		if t.Deleted == "" && t.ProjectID == id {
+			n++
+		}
+	}
+	return n
+}
+
+// inProject — подходит ли задача t под Query.Project (вызывать под mu).
+func (m *MemoryStore) inProject(t *Task, project int64) bool {
+	if project != 0 {
+		return t.ProjectID == project
+	}
+	p, ok := m.projects[t.ProjectID]
+	return !ok || !p.Archived
+}
+
+// Projects возвращает копии всех проектов по возрастанию id.
+func (m *MemoryStore) Projects() ([]*Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]*Project, 0, len(m.projects))
+	for _, p := range m.projects {
+		c := *p
+		c.Tasks = m.projectTasks(p.ID)
+		out = append(out, &c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// GetProject возвращает копию проекта или ErrProjectNotFound.
+func (m *MemoryStore) GetProject(id string) (*Project, error) {
+	n, err := projectID(id)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[n]
+	if !ok {
+		return nil, ErrProjectNotFound
+	}
+	c := *p
+	c.Tasks = m.projectTasks(n)
+	return &c, nil
+}
+
+// nameTaken — есть ли другой проект с именем name (вызывать под mu).
+func (m *MemoryStore) nameTaken(name string, id int64) bool {
+	for _, p := range m.projects {
+		if p.Name == name && p.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// AddProject сохраняет копию проекта под новым идентификатором.
+func (m *MemoryStore) AddProject(p *Project) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.nameTaken(p.Name, 0) {
+		return 0, ErrProjectExists
+	}
+	c := *p
+	c.ID = m.nextProject
+	c.Tasks = 0
+	m.nextProject++
+	m.projects[c.ID] = &c
+	return c.ID, nil
+}
+
+// UpdateProject перезаписывает имя, цвет и признак архива проекта.
+func (m *MemoryStore) UpdateProject(p *Project) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p.ID == DefaultProject && p.Archived {
+		return ErrDefaultProject
+	}
+	old, ok := m.projects[p.ID]
+	if !ok {
+		return ErrProjectNotFound
+	}
+	if m.nameTaken(p.Name, p.ID) {
+		return ErrProjectExists
+	}
+	old.Name, old.Color, old.Archived = p.Name, p.Color, p.Archived
+	return nil
+}
+
+// DeleteProject удаляет проект, перенося его задачи в DefaultProject.
+func (m *MemoryStore) DeleteProject(id string) error {
+	n, err := projectID(id)
+	if err != nil {
+		return err
+	}
+	if n == DefaultProject {
+		return ErrDefaultProject
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.projects[n]; !ok {
+		return ErrProjectNotFound
+	}
+	delete(m.projects, n)
+	for _, t := range m.tasks {
+		if t.ProjectID == n {
+			t.ProjectID = DefaultProject
+		}
+	}
+	return nil
+}
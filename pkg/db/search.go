@@ -0,0 +1,177 @@
+// Package db: полнотекстовый поиск задач.
+// Условие поиска (Term) разбивается на слова. Обычное условие совпадает, если
+// для каждого его слова в title или comment есть слово, начинающееся с него;
+// фраза в кавычках — если её слова целиком и подряд встречаются в title или comment.
+// Регистр не учитывается. Индекс и ранжирование — на стороне СУБД (FTS5 в SQLite,
+// tsvector в PostgreSQL), выделение совпадений в snippet — здесь, одинаково
+// для всех хранилищ.
+package db
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// snippetWords — сколько слов поля показывать в snippet.
+const snippetWords = 12
+
+// isWordRune — символ слова: буква или цифра (как в токенизаторе unicode61).
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// searchTerms разбивает строку поиска на слова в нижнем регистре.
+func searchTerms(search string) []string {
+	return strings.FieldsFunc(strings.ToLower(search), func(r rune) bool { return !isWordRune(r) })
+}
+
+// word — слово текста: границы в байтах и признак совпадения с поиском.
+type word struct {
+	start, end int
+	match      bool
+}
+
+// splitWords находит слова text и отмечает те, что начинаются с одного из terms.
+func splitWords(text string, terms []string) []word {
+	var out []word
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		w := word{start: start, end: end}
+		lw := strings.ToLower(text[start:end])
+		for _, t := range terms {
+			if strings.HasPrefix(lw, t) {
+				w.match = true
+				break
+			}
+		}
+		out = append(out, w)
+		start = -1
+	}
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+		} else {
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return out
+}
+
+// ftsExpr — выражение полнотекстового поиска диалекта d для условий terms
+// (все должны совпасть). ok == false, если в каком-то условии нет слов:
+// такое условие не совпадает ни с чем.
+func ftsExpr(d *dialect, terms []Term) (expr string, ok bool) {
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		words := searchTerms(t.Text)
+		if len(words) == 0 {
+			return "", false
+		}
+		parts = append(parts, d.ftsTerm(words, t.Exact))
+	}
+	return strings.Join(parts, d.ftsAnd), true
+}
+
+// highlightWords — слова условий, выделяемые в snippet.
+func highlightWords(terms []Term) []string {
+	var out []string
+	for _, t := range terms {
+		out = append(out, searchTerms(t.Text)...)
+	}
+	return out
+}
+
+// matchTerm сообщает, совпадает ли условие t с задачей, слова полей которой —
+// title и comment (уже в нижнем регистре). Используется MemoryStore.
+func matchTerm(title, comment []string, t Term) bool {
+	words := searchTerms(t.Text)
+	if len(words) == 0 {
+		return false
+	}
+	if t.Exact {
+		return containsPhrase(title, words) || containsPhrase(comment, words)
+	}
+	for _, w := range words {
+		if countMatches(title, []string{w}) == 0 && countMatches(comment, []string{w}) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// containsPhrase — встречаются ли phrase в words целиком и подряд.
+func containsPhrase(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(phrase)], phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// countMatches — сколько слов из words начинается с одного из terms.
+func countMatches(words, terms []string) int {
+	n := 0
+	for _, w := range words {
+		for _, t := range terms {
+			if strings.HasPrefix(w, t) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// snippet — фрагмент title (или comment, если совпадения только там)
+// длиной до snippetWords слов вокруг первого совпадения. Текст экранируется
+// для HTML, совпавшие слова обрамляются <mark>...</mark>.
+func snippet(t *Task, terms []string) string {
+	for _, text := range []string{t.Title, t.Comment} {
+		words := splitWords(text, terms)
+		first := -1
+		for i, w := range words {
+			if w.match {
+				first = i
+				break
+			}
+		}
+		if first < 0 {
+			continue
+		}
+
+		from := max(0, min(first-3, len(words)-snippetWords))
+		to := min(len(words), from+snippetWords)
+		var b strings.Builder
+		pos := words[from].start
+		if from > 0 {
+			b.WriteString("…")
+		} else {
+			pos = 0
+		}
+		for _, w := range words[from:to] {
+			b.WriteString(html.EscapeString(text[pos:w.start]))
+			if w.match {
+				b.WriteString("<mark>" + html.EscapeString(text[w.start:w.end]) + "</mark>")
+			} else {
+				b.WriteString(html.EscapeString(text[w.start:w.end]))
+			}
+			pos = w.end
+		}
+		if to < len(words) {
+			b.WriteString("…")
+		} else {
+			b.WriteString(html.EscapeString(text[pos:]))
+		}
+		return b.String()
+	}
+	return ""
+}
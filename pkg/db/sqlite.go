@@ -0,0 +1,28 @@
+// Package db: реализация TaskStore поверх SQLite.
+package db
+
+import "database/sql"
+
+// SQLiteStore — реализация TaskStore поверх SQLite (файл scheduler.db).
+type SQLiteStore struct {
+	sqlStore
+}
+
+// NewSQLiteStore оборачивает уже открытое и смигрированное соединение.
+func NewSQLiteStore(d *sql.DB) *SQLiteStore {
+	return &SQLiteStore{sqlStore{db: d, d: sqliteDialect}}
+}
+
+// OpenSQLite открывает (или создаёт) базу по пути dbFile, применяет
+// недостающие миграции и возвращает хранилище поверх неё.
+func OpenSQLite(dbFile string) (*SQLiteStore, error) {
+	d, err := Open(dbFile)
+	if err != nil {
+		return nil, err
+	}
+	s, err := openMigrated(d)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{s}, nil
+}
@@ -0,0 +1,608 @@
+// Package db: общая SQL-реализация TaskStore для SQLite и PostgreSQL.
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sqlStore — TaskStore поверх database/sql. Запросы написаны в синтаксисе
+// SQLite и переписываются под диалект соединения (см. dialect.go).
+type sqlStore struct {
+	db *sql.DB
+	d  *dialect
+}
+
+// Close закрывает соединение с базой.
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// openMigrated применяет к открытому соединению недостающие миграции.
+func openMigrated(d *sql.DB) (sqlStore, error) {
+	if err := Migrate(d, 0); err != nil {
+		_ = d.Close()
+		return sqlStore{}, err
+	}
+	return sqlStore{db: d, d: dialectOf(d)}, nil
+}
+
+// taskID разбирает строковый идентификатор; нечисловой id — это ErrNotFound
+// (PostgreSQL, в отличие от SQLite, отвергает такое сравнение с ошибкой).
+func taskID(id string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+	if err != nil {
+		return 0, ErrNotFound
+	}
+	return n, nil
+}
+
+// selectColumns и fromTasks — общие части SELECT для чтения задач вместе
+// с настройками повторения и служебными полями; selectTasks — они вместе.
+const (
+	selectColumns = `SELECT s.id, s.date, s.title, s.comment, s.repeat,
+	COALESCE(r.until, ''), COALESCE(r.max_count, 0), COALESCE(r.done_count, 0),
+	COALESCE(r.repeat_from, ''),
+	COALESCE((SELECT string_agg(e.date, ',') FROM task_exdates e WHERE e.task_id = s.id), ''),
+	COALESCE((SELECT string_agg(g.name, ',') FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.task_id = s.id), ''),
+	COALESCE(m.created, ''), COALESCE(m.deleted, ''), ` + taskProject + `,
+	COALESCE(m.priority, 0), COALESCE(m.scheduled, ''),
+	(SELECT count(*) FROM task_items i WHERE i.task_id = s.id),
+	(SELECT count(*) FROM task_items i WHERE i.task_id = s.id AND i.done),
+	COALESCE((SELECT string_agg(CAST(d.blocker_id AS TEXT), ',') FROM task_deps d WHERE d.task_id = s.id), ''),
+	EXISTS (SELECT 1 FROM task_deps d WHERE d.task_id = s.id AND NOT d.done)
+	`
+	fromTasks = `FROM scheduler s
+	LEFT JOIN task_repeat r ON r.task_id = s.id
+	LEFT JOIN task_meta m ON m.task_id = s.id`
+	selectTasks = selectColumns + fromTasks
+)
+
+// taskUrgency — цифра urgency задачи в запросах с fromTasks (Priorities
+// без пустого приоритета — четыре, поэтому 4 — у задач без приоритета).
+const taskUrgency = `CAST(4 - COALESCE(m.priority, 0) AS TEXT)`
+
+// notTrashed и inTrash — условия "задача не в корзине" для запросов с fromTasks
+// и "задача в корзине" для scheduler без псевдонима.
+const (
+	notTrashed = `COALESCE(m.deleted, '') = ''`
+	inTrash    = `id IN (SELECT task_id FROM task_meta WHERE deleted <> '')`
+)
+
+// scanner — общий интерфейс *sql.Row и *sql.Rows для scanTask.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTask читает одну строку, полученную запросом selectTasks.
+func scanTask(row scanner) (*Task, error) {
+	t := &Task{}
+	var exdates, tags, blockers string
+	var priority, items, done int
+	err := row.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat,
+		&t.RepeatUntil, &t.RepeatCount, &t.RepeatDone, &t.RepeatFrom, &exdates, &tags, &t.Created, &t.Deleted, &t.ProjectID,
+		&priority, &t.Scheduled, &items, &done, &blockers, &t.Blocked)
+	if err != nil {
+		return nil, err
+	}
+	t.Priority = priorityName(priority)
+	t.Progress = progress(done, items)
+	if exdates != "" {
+		t.Exdates = strings.Split(exdates, ",")
+		sort.Strings(t.Exdates)
+	}
+	if tags != "" {
+		t.Tags = strings.Split(tags, ",")
+		sort.Strings(t.Tags)
+	}
+	if blockers != "" {
+		ids, _ := parseIDs(strings.Split(blockers, ","))
+		t.BlockedBy = formatIDs(ids)
+	}
+	return t, nil
+}
+
+// replaceExdates заменяет набор дат-исключений задачи id внутри транзакции.
+func (s *sqlStore) replaceExdates(tx *sql.Tx, id int64, dates []string) error {
+	if _, err := tx.Exec(s.d.q(`DELETE FROM task_exdates WHERE task_id = ?`), id); err != nil {
+		return err
+	}
+	for _, d := range dates {
+		_, err := tx.Exec(s.d.q(insertExdate), id, d)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertExdate добавляет дату-исключение; повтор не считается ошибкой.
+const insertExdate = `INSERT INTO task_exdates (task_id, date) VALUES (?, ?)
+	ON CONFLICT (task_id, date) DO NOTHING`
+
+// AddTask вставляет новую задачу в таблицу scheduler и возвращает её идентификатор.
+func (s *sqlStore) AddTask(task *Task) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// RETURNING вместо LastInsertId: драйвер PostgreSQL его не поддерживает
+	const q = `INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?) RETURNING id`
+	var id int64
+	err = tx.QueryRow(s.d.q(q), task.Date, task.Title, task.Comment, task.Repeat).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if task.hasRepeatSettings() {
+		_, err = tx.Exec(s.d.q(
+			`INSERT INTO task_repeat (task_id, until, max_count, done_count, repeat_from)
+			 VALUES (?, ?, ?, ?, ?)`),
+			id, task.RepeatUntil, task.RepeatCount, task.RepeatDone, task.RepeatFrom)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := s.replaceExdates(tx, id, task.Exdates); err != nil {
+		return 0, err
+	}
+	if err := s.replaceTags(tx, id, task.Tags); err != nil {
+		return 0, err
+	}
+	if err := s.replaceItems(tx, id, task.Checklist); err != nil {
+		return 0, err
+	}
+	if err := s.replaceDeps(tx, id, task.BlockedBy); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(s.d.q(`INSERT INTO task_meta (task_id, created, project_id, priority, scheduled)
+		VALUES (?, ?, ?, ?, ?)`),
+		id, createdAt(task), projectOf(task), PriorityRank(task.Priority), task.Scheduled)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// Tasks возвращает страницу p задач, подходящих под q. Условия q превращаются
+// в параметризованный SQL:
+//   - From/To   → диапазон s.date;
+//   - Repeat    → есть ли правило повторения;
+//   - Include   → полнотекстовый поиск по title и comment (см. search.go),
+//     у каждой задачи заполнен Snippet;
+//   - Exclude   → задачи, где условие совпадает, отбрасываются;
+//   - Tags/NotTags → есть все / нет ни одной из меток;
+//   - Project   → задачи проекта (0 — кроме архивных проектов).
+//
+// Порядок — по ключу p.Sort и id (keyset: следующая страница — строки после
+// курсора), а при поиске без p.Sort — по релевантности (курсор — смещение).
+func (s *sqlStore) Tasks(q Query, p Page) (*TaskPage, error) {
+	if p.Limit <= 0 {
+		p.Limit = 50
+	}
+	page := &TaskPage{Tasks: make([]*Task, 0)}
+
+	var join []string
+	var joinArgs, whereArgs []any
+	where := []string{notTrashed}
+
+	if q.From != "" {
+		where = append(where, "s.date >= ?")
+		whereArgs = append(whereArgs, q.From)
+	}
+	if q.To != "" {
+		where = append(where, "s.date <= ?")
+		whereArgs = append(whereArgs, q.To)
+	}
+	switch q.Repeat {
+	case RepeatYes:
+		where = append(where, "s.repeat <> ''")
+	case RepeatNo:
+		where = append(where, "s.repeat = ''")
+	}
+	if len(q.Include) > 0 {
+		expr, ok := ftsExpr(s.d, q.Include)
+		if !ok {
+			return page, nil
+		}
+		// параметр стоит там, где он есть в тексте диалекта: в JOIN или в WHERE
+		join = append(join, s.d.ftsJoin)
+		where = append(where, s.d.ftsWhere)
+		if strings.Contains(s.d.ftsJoin, "?") {
+			joinArgs = append(joinArgs, expr)
+		} else {
+			whereArgs = append(whereArgs, expr)
+		}
+	}
+	for _, t := range q.Exclude {
+		if expr, ok := ftsExpr(s.d, []Term{t}); ok {
+			where = append(where, s.d.ftsExclude)
+			whereArgs = append(whereArgs, expr)
+		}
+	}
+	for _, tag := range q.Tags {
+		where = append(where, "EXISTS "+hasTag)
+		whereArgs = append(whereArgs, tag)
+	}
+	for _, tag := range q.NotTags {
+		where = append(where, "NOT EXISTS "+hasTag)
+		whereArgs = append(whereArgs, tag)
+	}
+	if q.Project != 0 {
+		where = append(where, taskProject+" = ?")
+		whereArgs = append(whereArgs, q.Project)
+	} else {
+		where = append(where, taskProject+" NOT IN "+archivedProjects)
+	}
+
+	from := fromTasks + strings.Join(join, "")
+	args := append(joinArgs, whereArgs...)
+	if p.Total {
+		query := "SELECT count(*) " + from
+		query += "\n WHERE " + strings.Join(where, " AND ")
+		if err := s.db.QueryRow(s.d.q(query), args...).Scan(&page.Total); err != nil {
+			return nil, err
+		}
+	}
+
+	relevance := p.Sort == "" && len(q.Include) > 0
+	order, offset := "", 0
+	if relevance {
+		order = s.d.ftsOrder + ", s.date, s.id"
+		if p.After != nil {
+			offset = p.After.Offset
+		}
+	} else {
+		if p.Sort == "" {
+			p.Sort = SortDate
+		}
+		dir, cmp := "", ">"
+		if p.Desc {
+			dir, cmp = " DESC", "<"
+		}
+		// выражения совпадают с sortValue
+		col := map[string]string{
+			SortDate:     "(s.date || " + taskUrgency + ")" + s.d.bytewise,
+			SortTitle:    "s.title" + s.d.bytewise,
+			SortCreated:  "COALESCE(m.created, '')" + s.d.bytewise,
+			SortPriority: "(" + taskUrgency + " || s.date)" + s.d.bytewise,
+		}[p.Sort]
+		order = "s.id" + dir
+		if col != "" {
+			order = col + dir + ", " + order
+		}
+		if p.After != nil {
+			if col == "" {
+				where = append(where, "s.id "+cmp+" ?")
+				args = append(args, p.After.ID)
+			} else {
+				where = append(where, "("+col+" "+cmp+" ? OR ("+col+" = ? AND s.id "+cmp+" ?))")
+				args = append(args, p.After.Value, p.After.Value, p.After.ID)
+			}
+		}
+	}
+
+	query := selectColumns + from + "\n WHERE " + strings.Join(where, " AND ")
+	// на одну строку больше: есть ли следующая страница
+	query += "\n ORDER BY " + order + "\n LIMIT ? OFFSET ?"
+	args = append(args, p.Limit+1, offset)
+
+	rows, err := s.db.Query(s.d.q(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(q.Include) > 0 {
+			t.Snippet = snippet(t, highlightWords(q.Include))
+		}
+		page.Tasks = append(page.Tasks, t)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	if len(page.Tasks) > p.Limit {
+		page.Tasks = page.Tasks[:p.Limit]
+		page.Next = nextCursor(page.Tasks, p, relevance)
+	}
+	return page, nil
+}
+
+// GetTask возвращает одну задачу по её строковому идентификатору (например, "185").
+// Если записи нет — возвращает ошибку вида "task not found".
+func (s *sqlStore) GetTask(id string) (*Task, error) {
+	n, err := taskID(id)
+	if err != nil {
+		return nil, err
+	}
+	row := s.db.QueryRow(s.d.q(selectTasks+`
+		 WHERE s.id = ? AND `+notTrashed), n)
+
+	t, err := scanTask(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if t.Checklist, err = s.checklist(t.ID); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// UpdateTask обновляет все основные поля задачи по её ID.
+// Настройки серии (until/count/repeat_from), даты-исключения, метки,
+// чек-лист и зависимости перезаписываются, счётчик выполненных повторений сохраняется.
+func (s *sqlStore) UpdateTask(task *Task) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(s.d.q(
+		`UPDATE scheduler
+		 SET date = ?, title = ?, comment = ?, repeat = ?
+		 WHERE id = ? AND NOT `+inTrash),
+		task.Date, task.Title, task.Comment, task.Repeat, task.ID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("incorrect id for updating task")
+	}
+	if task.hasRepeatSettings() {
+		_, err = tx.Exec(s.d.q(
+			`INSERT INTO task_repeat (task_id, until, max_count, repeat_from) VALUES (?, ?, ?, ?)
+			 ON CONFLICT(task_id) DO UPDATE SET until = excluded.until,
+			 max_count = excluded.max_count, repeat_from = excluded.repeat_from`),
+			task.ID, task.RepeatUntil, task.RepeatCount, task.RepeatFrom)
+	} else {
+		_, err = tx.Exec(s.d.q(
+			`UPDATE task_repeat SET until = '', max_count = 0, repeat_from = '' WHERE task_id = ?`), task.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.replaceExdates(tx, task.ID, task.Exdates); err != nil {
+		return err
+	}
+	if err := s.replaceTags(tx, task.ID, task.Tags); err != nil {
+		return err
+	}
+	if err := s.replaceItems(tx, task.ID, task.Checklist); err != nil {
+		return err
+	}
+	if err := s.replaceDeps(tx, task.ID, task.BlockedBy); err != nil {
+		return err
+	}
+	// у задач, созданных до миграции 6, строки task_meta нет — вставляем её
+	_, err = tx.Exec(s.d.q(`INSERT INTO task_meta (task_id, project_id, priority, scheduled) VALUES (?, ?, ?, ?)
+		ON CONFLICT (task_id) DO UPDATE SET project_id = excluded.project_id, priority = excluded.priority,
+		scheduled = excluded.scheduled`),
+		task.ID, projectOf(task), PriorityRank(task.Priority), task.Scheduled)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteTask переносит задачу в корзину (отметка task_meta.deleted).
+// Если задачи нет или она уже в корзине — возвращает ErrNotFound.
+func (s *sqlStore) DeleteTask(id string) error {
+	n, err := taskID(id)
+	if err != nil {
+		return err
+	}
+	// у задач, созданных до миграции 6, строки task_meta нет — вставляем её
+	res, err := s.db.Exec(s.d.q(
+		`INSERT INTO task_meta (task_id, deleted) SELECT id, ? FROM scheduler WHERE id = ? AND NOT `+inTrash+`
+		 ON CONFLICT (task_id) DO UPDATE SET deleted = excluded.deleted`), timestamp(), n)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Trash возвращает задачи из корзины, недавно удалённые первыми.
+func (s *sqlStore) Trash() ([]*Task, error) {
+	rows, err := s.db.Query(selectTasks + `
+		 WHERE NOT ` + notTrashed + `
+		 ORDER BY m.deleted DESC, s.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*Task, 0)
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// RestoreTask снимает с задачи отметку об удалении; зависящие от неё
+// задачи снова ждут её выполнения.
+func (s *sqlStore) RestoreTask(id string) error {
+	n, err := taskID(id)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(s.d.q(`UPDATE task_meta SET deleted = '' WHERE task_id = ? AND deleted <> ''`), n)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if err := s.setDepsDone(tx, n, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PurgeTask окончательно удаляет задачу из корзины; связанные строки
+// удаляются каскадом (ON DELETE CASCADE).
+func (s *sqlStore) PurgeTask(id string) error {
+	n, err := taskID(id)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(s.d.q(`DELETE FROM scheduler WHERE id = ? AND `+inTrash), n)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PurgeDeleted окончательно удаляет задачи, попавшие в корзину раньше before.
+func (s *sqlStore) PurgeDeleted(before string) (int64, error) {
+	res, err := s.db.Exec(s.d.q(
+		`DELETE FROM scheduler WHERE id IN (SELECT task_id FROM task_meta WHERE deleted <> '' AND deleted < ?)`), before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// UpdateDate обновляет только дату задачи с заданным id (и дату по расписанию).
+// Полезно при отметке задачи "выполненной" с пересчётом следующей даты.
+func (s *sqlStore) UpdateDate(next, scheduled string, id string) error {
+	n, err := taskID(id)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.setDate(tx, n, next, scheduled); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setDate переносит задачу n на дату next с датой по расписанию scheduled.
+func (s *sqlStore) setDate(tx *sql.Tx, n int64, next, scheduled string) error {
+	res, err := tx.Exec(s.d.q(`UPDATE scheduler SET date = ? WHERE id = ? AND NOT `+inTrash), next, n)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	// у задач, созданных до миграции 6, строки task_meta нет — вставляем её
+	_, err = tx.Exec(s.d.q(`INSERT INTO task_meta (task_id, scheduled) VALUES (?, ?)
+		ON CONFLICT (task_id) DO UPDATE SET scheduled = excluded.scheduled`), n, scheduled)
+	return err
+}
+
+// CompleteOccurrence переносит повторяющуюся задачу на дату next,
+// увеличивает счётчик выполненных повторений (если у задачи есть ограничения)
+// и снимает отметки с пунктов чек-листа.
+func (s *sqlStore) CompleteOccurrence(next, scheduled string, id string) error {
+	n, err := taskID(id)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.setDate(tx, n, next, scheduled); err != nil {
+		return err
+	}
+	_, err = tx.Exec(s.d.q(`UPDATE task_repeat SET done_count = done_count + 1 WHERE task_id = ?`), n)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(s.d.q(`UPDATE task_items SET done = FALSE WHERE task_id = ?`), n)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddExdate добавляет дату-исключение date к серии задачи id (повтор не ошибка).
+func (s *sqlStore) AddExdate(id string, date string) error {
+	n, err := taskID(id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.d.q(insertExdate), n, date)
+	return err
+}
+
+// SkipOccurrence добавляет дату-исключение date и переносит задачу на next
+// в одной транзакции: исключение без переноса не сохранится.
+func (s *sqlStore) SkipOccurrence(date, next, scheduled string, id string) error {
+	n, err := taskID(id)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.setDate(tx, n, next, scheduled); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.d.q(insertExdate), n, date); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteExdate убирает дату-исключение date у задачи id.
+// Если такого исключения не было — возвращает ошибку "exdate not found".
+func (s *sqlStore) DeleteExdate(id string, date string) error {
+	n, err := taskID(id)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(s.d.q(`DELETE FROM task_exdates WHERE task_id = ? AND date = ?`), n, date)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("exdate not found")
+	}
+	return nil
+}
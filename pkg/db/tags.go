@@ -0,0 +1,168 @@
+// Package db: метки задач (таблицы tags и task_tags).
+package db
+
+import (
+	"database/sql"
+	"slices"
+	"sort"
+)
+
+// hasTag — подзапрос "у задачи s есть метка ?".
+const hasTag = `(SELECT 1 FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+	WHERE tt.task_id = s.id AND g.name = ?)`
+
+// replaceTags заменяет набор меток задачи id внутри транзакции;
+// новые метки создаются.
+func (s *sqlStore) replaceTags(tx *sql.Tx, id int64, tags []string) error {
+	if _, err := tx.Exec(s.d.q(`DELETE FROM task_tags WHERE task_id = ?`), id); err != nil {
+		return err
+	}
+	for _, name := range sortedUnique(tags) {
+		_, err := tx.Exec(s.d.q(`INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`), name)
+		if err != nil {
+			return err
+		}
+		// VALUES, а не SELECT ?: так PostgreSQL выводит тип параметра из колонки
+		_, err = tx.Exec(s.d.q(
+			`INSERT INTO task_tags (task_id, tag_id) VALUES (?, (SELECT id FROM tags WHERE name = ?))`), id, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tags возвращает все метки по алфавиту с числом задач вне корзины.
+func (s *sqlStore) Tags() ([]TagCount, error) {
+	rows, err := s.db.Query(`SELECT g.name, count(s.id) FROM tags g
+		LEFT JOIN task_tags tt ON tt.tag_id = g.id
+		LEFT JOIN scheduler s ON s.id = tt.task_id
+			AND s.id NOT IN (SELECT task_id FROM task_meta WHERE deleted <> '')
+		GROUP BY g.name
+		ORDER BY g.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]TagCount, 0)
+	for rows.Next() {
+		var c TagCount
+		if err := rows.Scan(&c.Name, &c.Tasks); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// RenameTag переименовывает метку name в to.
+func (s *sqlStore) RenameTag(name, to string) error {
+	var n int
+	if err := s.db.QueryRow(s.d.q(`SELECT count(*) FROM tags WHERE name = ?`), to).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrTagExists
+	}
+	res, err := s.db.Exec(s.d.q(`UPDATE tags SET name = ? WHERE name = ?`), to, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// DeleteTag удаляет метку; связи с задачами удаляются каскадом.
+func (s *sqlStore) DeleteTag(name string) error {
+	res, err := s.db.Exec(s.d.q(`DELETE FROM tags WHERE name = ?`), name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// Tags возвращает все метки по алфавиту с числом задач вне корзины.
+func (m *MemoryStore) Tags() ([]TagCount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := map[string]int{}
+	for name := range m.tags {
+		counts[name] = 0
+	}
+	for _, t := range m.tasks {
+		if t.Deleted != "" {
+			continue
+		}
+		for _, name := range t.Tags {
+			counts[name]++
+		}
+	}
+	out := make([]TagCount, 0, len(counts))
+	for name, n := range counts {
+		out = append(out, TagCount{Name: name, Tasks: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// RenameTag переименовывает метку name в to у всех задач.
+func (m *MemoryStore) RenameTag(name, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tags[to] {
+		return ErrTagExists
+	}
+	if !m.tags[name] {
+		return ErrTagNotFound
+	}
+	delete(m.tags, name)
+	m.tags[to] = true
+	for _, t := range m.tasks {
+		for i, tag := range t.Tags {
+			if tag == name {
+				t.Tags[i] = to
+				t.Tags = sortedUnique(t.Tags)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteTag удаляет метку и снимает её со всех задач.
+func (m *MemoryStore) DeleteTag(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.tags[name] {
+		return ErrTagNotFound
+	}
+	delete(m.tags, name)
+	for _, t := range m.tasks {
+		t.Tags = slices.DeleteFunc(t.Tags, func(tag string) bool { return tag == name })
+	}
+	return nil
+}
+
+// memoryHasTags — есть ли у задачи t все метки q.Tags и ни одной из q.NotTags.
+func memoryHasTags(t *Task, q Query) bool {
+	for _, name := range q.Tags {
+		if !slices.Contains(t.Tags, name) {
+			return false
+		}
+	}
+	for _, name := range q.NotTags {
+		if slices.Contains(t.Tags, name) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,156 +1,427 @@
-// Package db: модели и операции с задачами (CRUD) поверх SQLite.
+// Package db: модель задачи и интерфейс хранилища задач.
 package db
 
 import (
-	"database/sql"
-	"fmt"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Task описывает одну задачу из таблицы scheduler.
 // В БД все поля (кроме id) текстовые; в коде id удобнее хранить как int64.
+//
+// RepeatUntil, RepeatCount и RepeatDone — ограничения серии повторений
+// (таблица task_repeat). Нулевые значения означают "без ограничения"
+// и не попадают в JSON. RepeatFrom — точка отсчёта интервала: "" — от даты
+// по расписанию, RepeatFromDone — от дня фактического выполнения.
+// Scheduled — дата текущего повторения по расписанию, если правило
+// "| next/prev" перенесло её на рабочий день (task_meta.scheduled; пусто —
+// совпадает с Date): от неё, а не от перенесённой Date отсчитываются интервалы.
+// Exdates — даты-исключения серии (таблица task_exdates).
+// Tags — метки задачи по алфавиту (таблицы tags и task_tags).
+// ProjectID — проект, в котором лежит задача (task_meta.project_id);
+// 0 при записи — DefaultProject.
+// Priority — приоритет: одно из Priorities, "" — без приоритета.
+// Checklist — пункты чек-листа по порядку (таблица task_items); их читает
+// только GetTask, а в списках Tasks есть лишь Progress — "выполнено/всего"
+// (пусто, если пунктов нет), при записи Progress не используется.
+// BlockedBy — id задач, которые нужно выполнить раньше этой (таблица task_deps),
+// по возрастанию; Blocked — среди них есть не выполненные после появления
+// зависимости (удаление в корзину выполнением не считается), только для чтения.
+// Created — время создания (RFC 3339, UTC; таблица task_meta), только для чтения.
+// Deleted — когда задача перенесена в корзину (только у задач из Trash).
+// Snippet заполняется только в результатах поиска: фрагмент текста задачи,
+// совпадения выделены <mark>...</mark> (остальной текст экранирован для HTML).
 type Task struct {
 	ID      int64  `json:"id,string" db:"id"`
 	Date    string `json:"date" db:"date"`
 	Title   string `json:"title" db:"title"`
 	Comment string `json:"comment" db:"comment"`
 	Repeat  string `json:"repeat" db:"repeat"`
+
+	RepeatUntil string `json:"repeat_until,omitempty" db:"until"`
+	RepeatCount int64  `json:"repeat_count,string,omitempty" db:"max_count"`
+	RepeatDone  int64  `json:"repeat_done,string,omitempty" db:"done_count"`
+	RepeatFrom  string `json:"repeat_from,omitempty" db:"repeat_from"`
+	Scheduled   string `json:"scheduled,omitempty" db:"-"`
+
+	Exdates []string `json:"exdates,omitempty" db:"-"`
+	Tags    []string `json:"tags,omitempty" db:"-"`
+	Created string   `json:"created,omitempty" db:"-"`
+	Deleted string   `json:"deleted,omitempty" db:"-"`
+	Snippet string   `json:"snippet,omitempty" db:"-"`
+
+	ProjectID int64  `json:"project_id,string,omitempty" db:"-"`
+	Priority  string `json:"priority,omitempty" db:"-"`
+
+	Checklist []ChecklistItem `json:"checklist,omitempty" db:"-"`
+	Progress  string          `json:"progress,omitempty" db:"-"`
+
+	BlockedBy []string `json:"blocked_by,omitempty" db:"-"`
+	Blocked   bool     `json:"blocked,omitempty" db:"-"`
 }
 
-// AddTask вставляет новую задачу в таблицу scheduler и возвращает её идентификатор.
-func AddTask(task *Task) (int64, error) {
-	const q = `INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`
-	res, err := DB.Exec(q, task.Date, task.Title, task.Comment, task.Repeat)
-	if err != nil {
-		return 0, err
-	}
-	return res.LastInsertId()
+// ChecklistItem — пункт чек-листа задачи. ID назначает хранилище; при записи
+// задачи пункт с ID, который уже есть у этой задачи, сохраняет его.
+type ChecklistItem struct {
+	ID    int64  `json:"id,string,omitempty"`
+	Title string `json:"title"`
+	Done  bool   `json:"done"`
 }
 
-// Tasks возвращает список задач, отсортированных по дате (возрастание).
-// Поддерживает простой поиск:
-//   - search == ""        → просто LIMIT
-//   - search как 02.01.2006 → фильтр по точной дате (конвертируем в 20060102)
-//   - иначе               → LIKE по title и comment
-func Tasks(limit int, search string) ([]*Task, error) {
-	if limit <= 0 {
-		limit = 50
+// progress — значение Task.Progress для done выполненных пунктов из total.
+func progress(done, total int) string {
+	if total == 0 {
+		return ""
 	}
+	return strconv.Itoa(done) + "/" + strconv.Itoa(total)
+}
 
-	var rows *sql.Rows
-	var err error
-
-	if search == "" {
-		rows, err = DB.Query(
-			`SELECT id, date, title, comment, repeat
-			 FROM scheduler
-			 ORDER BY date
-			 LIMIT ?`, limit)
-	} else {
-		// Пытаемся распознать строку как дату 02.01.2006.
-		if t, e := time.Parse("02.01.2006", search); e == nil {
-			dateStr := t.Format("20060102")
-			rows, err = DB.Query(
-				`SELECT id, date, title, comment, repeat
-				 FROM scheduler
-				 WHERE date = ?
-				 ORDER BY date
-				 LIMIT ?`, dateStr, limit)
-		} else {
-			// Иначе ищем подстроку в title/comment через LIKE (регистр-чувствительный).
-			p := "%" + search + "%"
-			rows, err = DB.Query(
-				`SELECT id, date, title, comment, repeat
-				 FROM scheduler
-				 WHERE title LIKE ? OR comment LIKE ?
-				 ORDER BY date
-				 LIMIT ?`, p, p, limit)
-		}
-	}
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// RepeatFromDone — значение Task.RepeatFrom для отсчёта от дня выполнения.
+const RepeatFromDone = "done"
+
+// Priorities — приоритеты задач по возрастанию важности; "" — без приоритета.
+// В БД хранится номер в этом списке (task_meta.priority).
+var Priorities = []string{"", "low", "medium", "high", "urgent"}
 
-	var out []*Task
-	for rows.Next() {
-		t := &Task{}
-		if err := rows.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat); err != nil {
-			return nil, err
+// PriorityRank — номер приоритета p в Priorities (0 — без приоритета или неизвестный).
+func PriorityRank(p string) int {
+	for i, name := range Priorities {
+		if name == p {
+			return i
 		}
-		out = append(out, t)
-	}
-	if rows.Err() != nil {
-		return nil, rows.Err()
 	}
+	return 0
+}
 
-	// Чтобы JSON-маршалинг выдавал "tasks": [] (а не null) при отсутствии данных.
-	if out == nil {
-		out = make([]*Task, 0)
+// priorityName — приоритет по номеру из БД.
+func priorityName(rank int) string {
+	if rank < 0 || rank >= len(Priorities) {
+		return ""
 	}
-	return out, nil
+	return Priorities[rank]
 }
 
-// GetTask возвращает одну задачу по её строковому идентификатору (например, "185").
-// Если записи нет — возвращает ошибку вида "task not found".
-func GetTask(id string) (*Task, error) {
-	row := DB.QueryRow(
-		`SELECT id, date, title, comment, repeat
-		 FROM scheduler
-		 WHERE id = ?`, id)
+// urgency — цифра для сортировки по приоритету: "0" у самых важных задач.
+func urgency(p string) string {
+	return strconv.Itoa(len(Priorities) - 1 - PriorityRank(p))
+}
 
-	t := &Task{}
-	if err := row.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("task not found")
-		}
-		return nil, err
+// Query — разобранный поисковый запрос к списку задач (строку разбирает api.ParseQuery).
+// Нулевое значение — все задачи.
+type Query struct {
+	From, To string   // диапазон дат 20060102 включительно; "" — без границы
+	Repeat   string   // "" — любые, RepeatYes — только повторяющиеся, RepeatNo — разовые
+	Include  []Term   // все должны встретиться в title/comment (с ранжированием)
+	Exclude  []Term   // ни один не должен встретиться
+	Tags     []string // у задачи должны быть все эти метки
+	NotTags  []string // и ни одной из этих
+	Project  int64    // только задачи проекта; 0 — всех проектов, кроме архивных
+}
+
+// Значения Query.Repeat.
+const (
+	RepeatYes = "yes"
+	RepeatNo  = "no"
+)
+
+// Term — условие полнотекстового поиска: слово (совпадает с началом слов задачи)
+// или фраза в кавычках (Exact: слова целиком и подряд). Текст разбивается на
+// слова хранилищем; условие без букв и цифр не совпадает ни с чем.
+type Term struct {
+	Text  string
+	Exact bool
+}
+
+// Ключи сортировки списка задач (Page.Sort). SortDate — по дате, в один
+// день сначала важные; SortPriority — сначала важные, при равном
+// приоритете — по дате.
+const (
+	SortDate     = "date"
+	SortTitle    = "title"
+	SortID       = "id"
+	SortCreated  = "created"
+	SortPriority = "priority"
+)
+
+// Page — какую страницу списка задач вернуть.
+type Page struct {
+	Limit int
+	// Sort — ключ сортировки (при равных значениях — по id); "" — по дате,
+	// а при полнотекстовом поиске — по релевантности.
+	Sort  string
+	Desc  bool
+	After *Cursor // позиция последней задачи предыдущей страницы; nil — первая страница
+	Total bool    // посчитать, сколько всего задач подходит под запрос
+}
+
+// Cursor — позиция в списке: значение ключа сортировки и id последней задачи
+// страницы. Следующая страница начинается строго после неё, поэтому
+// добавленные задачи не сдвигают выдачу. При сортировке по релевантности
+// порядок меняется вместе с индексом, и позиция — просто смещение Offset.
+type Cursor struct {
+	Value  string
+	ID     int64
+	Offset int
+}
+
+// TaskPage — страница списка задач.
+type TaskPage struct {
+	Tasks []*Task
+	Next  *Cursor // nil — страница последняя
+	Total int     // только при Page.Total
+}
+
+// sortValue — значение ключа сортировки key у задачи t (для курсора).
+// Составные ключи — строки, сравниваемые побайтно: дата и цифра urgency.
+func sortValue(t *Task, key string) string {
+	switch key {
+	case SortTitle:
+		return t.Title
+	case SortCreated:
+		return t.Created
+	case SortPriority:
+		return urgency(t.Priority) + t.Date
+	case SortID:
+		return ""
 	}
-	return t, nil
-}
-
-// UpdateTask обновляет все основные поля задачи по её ID.
-func UpdateTask(task *Task) error {
-	res, err := DB.Exec(
-		`UPDATE scheduler
-		 SET date = ?, title = ?, comment = ?, repeat = ?
-		 WHERE id = ?`,
-		task.Date, task.Title, task.Comment, task.Repeat, task.ID)
-	if err != nil {
-		return err
+	return t.Date + urgency(t.Priority)
+}
+
+// createdAt — время создания для новой задачи: заданное или текущее.
+func createdAt(t *Task) string {
+	if t.Created != "" {
+		return t.Created
 	}
-	n, err := res.RowsAffected()
-	if err != nil {
-		return err
+	return timestamp()
+}
+
+// nextCursor — курсор после последней задачи страницы tasks.
+func nextCursor(tasks []*Task, p Page, relevance bool) *Cursor {
+	if relevance {
+		offset := len(tasks)
+		if p.After != nil {
+			offset += p.After.Offset
+		}
+		return &Cursor{Offset: offset}
 	}
-	if n == 0 {
-		return fmt.Errorf("incorrect id for updating task")
+	last := tasks[len(tasks)-1]
+	return &Cursor{Value: sortValue(last, p.Sort), ID: last.ID}
+}
+
+// timestamp — текущее время в формате полей Created и Deleted.
+func timestamp() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// Completion — запись истории выполнения: задача TaskID с названием Title
+// (на момент выполнения), назначенная на Date, выполнена в Completed (RFC 3339, UTC).
+type Completion struct {
+	ID        int64  `json:"id,string"`
+	TaskID    int64  `json:"task_id,string"`
+	Title     string `json:"title"`
+	Date      string `json:"date"`
+	Completed string `json:"completed"`
+}
+
+// HistoryFilter — отбор записей истории.
+type HistoryFilter struct {
+	TaskID       int64  // 0 — все задачи
+	Since, Until string // Since <= Completed < Until (RFC 3339, UTC); "" — без границы
+	Limit        int
+}
+
+// AuditEntry — запись журнала изменений: кто (Principal, с адреса Remote),
+// когда (Time, RFC 3339, UTC) и что (Action) сделал с задачей TaskID.
+// Diff — JSON-объект изменившихся полей задачи: {"поле": [было, стало]}.
+type AuditEntry struct {
+	ID        int64  `json:"id,string"`
+	Time      string `json:"time"`
+	Action    string `json:"action"`
+	TaskID    int64  `json:"task_id,string"`
+	Principal string `json:"principal"`
+	Remote    string `json:"remote"`
+	Diff      string `json:"-"`
+}
+
+// AuditFilter — отбор записей журнала; пустые поля не ограничивают.
+type AuditFilter struct {
+	TaskID       int64
+	Action       string
+	Principal    string
+	Since, Until string // Since <= Time < Until (RFC 3339, UTC)
+	// Asc — от старых к новым, начиная после записи AfterID (для выгрузки);
+	// иначе — сначала новые.
+	Asc     bool
+	AfterID int64
+	Limit   int
+}
+
+// TagCount — метка и число задач с ней (задачи в корзине не считаются).
+type TagCount struct {
+	Name  string `json:"name"`
+	Tasks int    `json:"tasks"`
+}
+
+// Project — проект (отдельный список задач). Tasks — сколько в нём задач
+// вне корзины, только для чтения. Архивный проект не принимает новые задачи,
+// а его задачи видны только в списке самого проекта.
+type Project struct {
+	ID       int64  `json:"id,string"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Archived bool   `json:"archived"`
+	Tasks    int    `json:"tasks"`
+}
+
+// DefaultProject — проект «Входящие»: он создаётся миграцией, в нём задачи
+// без явного проекта и задачи удалённых проектов. Удалить или убрать его
+// в архив нельзя.
+const DefaultProject int64 = 1
+
+// projectOf — проект задачи t для записи в хранилище.
+func projectOf(t *Task) int64 {
+	if t.ProjectID == 0 {
+		return DefaultProject
 	}
-	return nil
+	return t.ProjectID
+}
+
+// ErrNotFound — задачи с таким идентификатором нет.
+var ErrNotFound = errors.New("task not found")
+
+// ErrItemNotFound — у задачи нет пункта чек-листа с таким идентификатором.
+var ErrItemNotFound = errors.New("checklist item not found")
+
+// Ошибки записи зависимостей (Task.BlockedBy) в AddTask и UpdateTask.
+var (
+	ErrBlockerNotFound = errors.New("blocking task not found")
+	ErrDependencyCycle = errors.New("dependency cycle")
+)
+
+// ErrTagNotFound и ErrTagExists — ошибки RenameTag и DeleteTag.
+var (
+	ErrTagNotFound = errors.New("tag not found")
+	ErrTagExists   = errors.New("tag already exists")
+)
+
+// Ошибки методов проектов.
+var (
+	ErrProjectNotFound = errors.New("project not found")
+	ErrProjectExists   = errors.New("project already exists")
+	ErrDefaultProject  = errors.New("default project cannot be archived or deleted")
+)
+
+// TaskStore — хранилище задач. Обработчики API работают только через него,
+// поэтому хранилище можно подменить (SQLite, память) и держать
+// несколько независимых экземпляров в одном процессе.
+type TaskStore interface {
+	// AddTask сохраняет новую задачу и возвращает её идентификатор.
+	// Задачи из BlockedBy должны быть вне корзины (иначе ErrBlockerNotFound).
+	AddTask(task *Task) (int64, error)
+	// Tasks — страница p задач, подходящих под q.
+	Tasks(q Query, p Page) (*TaskPage, error)
+	// GetTask — задача по строковому идентификатору или ErrNotFound.
+	GetTask(id string) (*Task, error)
+	// UpdateTask перезаписывает поля задачи (счётчик выполненных повторений сохраняется).
+	// Новые зависимости проверяются как в AddTask; замыкающие цикл — ErrDependencyCycle.
+	// Оставшиеся зависимости сохраняют отметку о выполнении блокирующей задачи.
+	UpdateTask(task *Task) error
+	// DeleteTask переносит задачу в корзину: дальше её видят только Trash,
+	// RestoreTask и PurgeTask, для остальных методов её нет (ErrNotFound).
+	DeleteTask(id string) error
+	// Trash — задачи в корзине, недавно удалённые первыми.
+	Trash() ([]*Task, error)
+	// RestoreTask возвращает задачу из корзины (ErrNotFound, если её там нет);
+	// задачи, которые от неё зависят, снова ждут её выполнения.
+	RestoreTask(id string) error
+	// PurgeTask окончательно удаляет задачу из корзины (ErrNotFound, если её там нет).
+	PurgeTask(id string) error
+	// PurgeDeleted окончательно удаляет задачи, попавшие в корзину раньше before
+	// (RFC 3339), и возвращает их число.
+	PurgeDeleted(before string) (int64, error)
+	// UpdateDate переносит задачу на дату next; scheduled — дата по расписанию
+	// до переноса на рабочий день (пусто — совпадает с next).
+	UpdateDate(next, scheduled string, id string) error
+	// CompleteOccurrence переносит задачу на next (scheduled — как в UpdateDate),
+	// учитывает выполненное повторение и снимает отметки с пунктов чек-листа
+	// (он начинается заново).
+	CompleteOccurrence(next, scheduled string, id string) error
+	// CheckItem отмечает пункт чек-листа задачи выполненным (done) или нет.
+	CheckItem(id string, item int64, done bool) error
+	// AddCompletion записывает выполнение в историю (ID и пустое Completed
+	// заполняются хранилищем); задачи, которые от неё зависят, больше её не ждут.
+	AddCompletion(c *Completion) error
+	// History — записи истории под фильтр f, сначала последние.
+	History(f HistoryFilter) ([]*Completion, error)
+	// AddAudit добавляет запись в журнал изменений (ID и пустое Time
+	// заполняются хранилищем).
+	AddAudit(e *AuditEntry) error
+	// Audit — записи журнала под фильтр f.
+	Audit(f AuditFilter) ([]*AuditEntry, error)
+	// Tags — все метки по алфавиту (и те, что сейчас ни у одной задачи).
+	Tags() ([]TagCount, error)
+	// RenameTag переименовывает метку у всех задач.
+	RenameTag(name, to string) error
+	// DeleteTag удаляет метку (и снимает её со всех задач).
+	DeleteTag(name string) error
+	// Projects — все проекты (и архивные) по возрастанию id.
+	Projects() ([]*Project, error)
+	// GetProject — проект по строковому идентификатору или ErrProjectNotFound.
+	GetProject(id string) (*Project, error)
+	// AddProject создаёт проект и возвращает его идентификатор
+	// (ErrProjectExists, если имя занято).
+	AddProject(p *Project) (int64, error)
+	// UpdateProject перезаписывает имя, цвет и признак архива проекта.
+	UpdateProject(p *Project) error
+	// DeleteProject удаляет проект; его задачи (и в корзине) переходят в DefaultProject.
+	DeleteProject(id string) error
+	// AddExdate и DeleteExdate управляют датами-исключениями серии.
+	AddExdate(id string, date string) error
+	DeleteExdate(id string, date string) error
+	// SkipOccurrence добавляет исключение date (текущую дату задачи) и переносит
+	// задачу на next (scheduled — как в UpdateDate) одной транзакцией.
+	SkipOccurrence(date, next, scheduled string, id string) error
+	// Close освобождает ресурсы хранилища.
+	Close() error
 }
 
-// DeleteTask удаляет задачу по её идентификатору.
-// Если ни одна строка не затронута — возвращает ошибку "task not found".
-func DeleteTask(id string) error {
-	res, err := DB.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
-	if err != nil {
-		return err
+// hasRepeatSettings сообщает, есть ли у задачи собственные настройки серии.
+// COUNT в правиле RRULE тоже требует счётчика выполненных повторений.
+func (t *Task) hasRepeatSettings() bool {
+	return t.RepeatUntil != "" || t.RepeatCount > 0 || t.RepeatDone > 0 || t.RepeatFrom != "" ||
+		hasRRuleCount(t.Repeat)
+}
+
+// hasRRuleCount — есть ли в правиле "RRULE:..." часть COUNT=.
+func hasRRuleCount(repeat string) bool {
+	rule, ok := strings.CutPrefix(strings.ToUpper(strings.TrimSpace(repeat)), "RRULE:")
+	if !ok {
+		return false
 	}
-	if n, _ := res.RowsAffected(); n == 0 {
-		return fmt.Errorf("task not found")
+	for _, part := range strings.Split(rule, ";") {
+		if strings.HasPrefix(strings.TrimSpace(part), "COUNT=") {
+			return true
+		}
 	}
-	return nil
+	return false
 }
 
-// UpdateDate обновляет только поле date у задачи с заданным id.
-// Полезно при отметке задачи "выполненной" с пересчётом следующей даты.
-func UpdateDate(next string, id string) error {
-	res, err := DB.Exec(`UPDATE scheduler SET date = ? WHERE id = ?`, next, id)
-	if err != nil {
-		return err
+// sortedUnique — отсортированные строки без повторов (даты-исключения, метки).
+func sortedUnique(items []string) []string {
+	if len(items) == 0 {
+		return nil
 	}
-	if n, _ := res.RowsAffected(); n == 0 {
-		return fmt.Errorf("task not found")
+	out := append([]string(nil), items...)
+	sort.Strings(out)
+	n := 0
+	for i, d := range out {
+		if i == 0 || d != out[n-1] {
+			out[n] = d
+			n++
+		}
 	}
-	return nil
+	return out[:n]
 }
@@ -6,43 +6,60 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"todo/pkg/api"
+	"todo/pkg/db"
 )
 
 // Start запускает простой HTTP-сервер.
 // Делает три вещи:
-//  1. Регистрирует API-эндпоинты (api.Init()) в стандартном mux.
+//  1. Регистрирует API-эндпоинты поверх хранилища store в собственном mux
+//     и раз в час очищает корзину от задач старше срока хранения (TODO_TRASH_DAYS).
 //  2. Вешает раздачу статических файлов из каталога ./web на корень "/"
 //     (index.html, js, css, favicon и т.п.).
 //  3. Запускает http.ListenAndServe на адресе вида ":<порт>".
 //
 // Порт по умолчанию — 7540. Можно переопределить переменной окружения TODO_PORT.
 // Пример запуска: TODO_PORT=8080 go run .
-func Start() error {
+func Start(store db.TaskStore) error {
 	// каталог фронтенда со статикой
 	webDir := "./web"
 
 	// получаем адрес (":7540" по умолчанию или из TODO_PORT)
 	addr := getAddr()
 
-	// регистрируем API-обработчики в стандартном mux
-	api.Init()
+	// регистрируем API-обработчики в отдельном mux (не трогаем http.DefaultServeMux)
+	mux := http.NewServeMux()
+	a := api.New(store)
+	a.Register(mux)
+	go purgeTrash(a)
 
-	// раздача фронтенда (тоже в стандартный mux)
+	// раздача фронтенда (в тот же mux)
 	// Примеры:
 	//   GET /            -> ./web/index.html
 	//   GET /js/...      -> ./web/js/...
 	//   GET /css/...     -> ./web/css/...
 	//   GET /favicon.ico -> ./web/favicon.ico
 	fs := http.FileServer(http.Dir(webDir))
-	http.Handle("/", fs)
+	mux.Handle("/", fs)
 
 	// простое сообщение в консоль, чтобы видеть, что сервер поднялся
 	fmt.Println("Сервер запущен на порту", addr)
 
-	// передаём nil → используем http.DefaultServeMux, где уже всё зарегистрировано выше
-	return http.ListenAndServe(addr, nil)
+	return http.ListenAndServe(addr, mux)
+}
+
+// purgeTrash периодически удаляет из корзины задачи старше срока хранения.
+func purgeTrash(a *api.API) {
+	for {
+		if n, err := a.PurgeTrash(); err != nil {
+			fmt.Println("Ошибка очистки корзины:", err)
+		} else if n > 0 {
+			fmt.Println("Из корзины удалено задач:", n)
+		}
+		time.Sleep(time.Hour)
+	}
 }
 
 // getAddr возвращает строку адреса вида ":<порт>".
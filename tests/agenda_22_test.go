@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/db"
+)
+
+// agendaDays возвращает повестку как "дата → title задач" ("*" в конце — повторение).
+func agendaDays(t *testing.T, srv *httptest.Server, params string) map[string][]string {
+	ret := storeRequest(t, srv, http.MethodGet, "api/agenda?"+params, nil)
+	require.Nil(t, ret["error"], ret)
+	days := map[string][]string{}
+	list, _ := ret["days"].([]any)
+	for _, it := range list {
+		day := it.(map[string]any)
+		var titles []string
+		for _, tt := range day["tasks"].([]any) {
+			task := tt.(map[string]any)
+			title := task["title"].(string)
+			if task["virtual"] == true {
+				title += "*"
+			}
+			titles = append(titles, title)
+		}
+		days[day["date"].(string)] = titles
+	}
+	return days
+}
+
+// checkAgenda проверяет /api/agenda поверх store.
+func checkAgenda(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	// понедельник не раньше чем через неделю
+	monday := time.Now().AddDate(0, 0, 7)
+	monday = monday.AddDate(0, 0, -(int(monday.Weekday())+6)%7)
+	day := func(n int) string { return monday.AddDate(0, 0, n).Format(`20060102`) }
+	storeAddTask(t, srv, map[string]any{"date": day(2), "title": "Планёрка", "repeat": "d 7"})
+	storeAddTask(t, srv, map[string]any{"date": day(4), "title": "Врач"})
+	storeAddTask(t, srv, map[string]any{"date": day(5), "title": "Отчёт", "repeat": "d 1", "repeat_count": "3"})
+	storeAddTask(t, srv, map[string]any{"date": day(9), "title": "Уборка", "repeat": "d 1", "exdates": []string{day(10)}})
+
+	assert.Equal(t, map[string][]string{
+		day(2): {"Планёрка"},
+		day(4): {"Врач"},
+		day(5): {"Отчёт"},
+		day(6): {"Отчёт*"},
+	}, agendaDays(t, srv, "week="+day(3)))
+
+	// серия ограничена repeat_count, исключённая дата пропускается
+	assert.Equal(t, map[string][]string{
+		day(7):  {"Отчёт*"},
+		day(9):  {"Планёрка*", "Уборка"},
+		day(11): {"Уборка*"},
+		day(12): {"Уборка*"},
+		day(13): {"Уборка*"},
+	}, agendaDays(t, srv, "week="+day(7)))
+
+	assert.Equal(t, map[string][]string{
+		day(16): {"Планёрка*", "Уборка*"},
+		day(17): {"Уборка*"},
+	}, agendaDays(t, srv, "from="+day(16)+"&to="+day(17)))
+
+	ret := storeRequest(t, srv, http.MethodGet, "api/agenda?month="+day(0)[:6], nil)
+	assert.Equal(t, day(0)[:6]+"01", ret["from"])
+
+	for _, params := range []string{
+		"", "week=2024", "month=202413", "from=20240110&to=20240101",
+		"from=20240101&to=20260101", "from=20240101", "week=20240101&month=202401",
+	} {
+		ret := storeRequest(t, srv, http.MethodGet, "api/agenda?"+params, nil)
+		assert.NotEmpty(t, ret["error"], params)
+	}
+}
+
+func TestAgenda(t *testing.T) {
+	forEachStore(t, checkAgenda)
+}
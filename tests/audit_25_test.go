@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/api"
+	"todo/pkg/db"
+)
+
+// auditActions возвращает записи журнала как "действие task_id" в порядке выдачи.
+func auditActions(t *testing.T, srv *httptest.Server, params string) []string {
+	ret := storeRequest(t, srv, http.MethodGet, "api/admin/audit?"+params, nil)
+	require.Nil(t, ret["error"], ret)
+	list, _ := ret["entries"].([]any)
+	out := []string{}
+	for _, it := range list {
+		m := it.(map[string]any)
+		out = append(out, m["action"].(string)+" "+m["task_id"].(string))
+	}
+	return out
+}
+
+// signinAs входит на srv с паролем password под именем name и возвращает
+// клиента с cookie токена.
+func signinAs(t *testing.T, srv *httptest.Server, name, password string) *http.Client {
+	ret := storeRequest(t, srv, http.MethodPost, "api/signin", map[string]any{"password": password, "name": name})
+	token, _ := ret["token"].(string)
+	require.NotEmpty(t, token, ret)
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	u, _ := url.Parse(srv.URL)
+	jar.SetCookies(u, []*http.Cookie{{Name: "token", Value: token}})
+	return &http.Client{Jar: jar}
+}
+
+// checkAudit проверяет журнал изменений через API поверх store.
+func checkAudit(t *testing.T, store db.TaskStore) {
+	a := api.New(store)
+	a.SetPassword("secret")
+	a.SetUser("alice", "alice-pw", true)
+	a.SetUser("bob", "bob-pw", false)
+	srv := httptest.NewServer(a.Handler())
+	t.Cleanup(srv.Close)
+
+	// имя в журнале подтверждается паролем этого пользователя, а не общим
+	for _, in := range []map[string]any{
+		{"password": "secret", "name": "alice"},
+		{"password": "bob-pw", "name": "alice"},
+		{"password": "x", "name": "mallory"},
+		{"password": "secret", "name": "user"},
+	} {
+		assert.Equal(t, "invalid password", storeRequest(t, srv, http.MethodPost, "api/signin", in)["error"], in)
+	}
+	bob := signinAs(t, srv, "bob", "bob-pw")
+	srv.Client().Jar = signinAs(t, srv, "alice", "alice-pw").Jar
+
+	date := time.Now().Format(`20060102`)
+	id := storeAddTask(t, srv, map[string]any{"date": date, "title": "Полить цветы", "repeat": "d 2"})
+	storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": id, "title": "Полить фикус"})
+	storeRequest(t, srv, http.MethodPost, "api/task/done?id="+id, nil)
+	storeRequest(t, srv, http.MethodDelete, "api/task?id="+id, nil)
+	storeRequest(t, srv, http.MethodPost, "api/trash/restore?id="+id, nil)
+	// неудачные запросы в журнал не попадают
+	storeRequest(t, srv, http.MethodDelete, "api/task?id=100500", nil)
+
+	assert.Equal(t, []string{"restore " + id, "delete " + id, "done " + id, "update " + id, "create " + id},
+		auditActions(t, srv, ""))
+	assert.Equal(t, []string{"update " + id}, auditActions(t, srv, "action=update&principal=alice&from="+date+"&to="+date))
+	assert.Empty(t, auditActions(t, srv, "principal=bob"))
+	assert.Empty(t, auditActions(t, srv, "task_id=100500"))
+	assert.Len(t, auditActions(t, srv, "limit=2"), 2)
+
+	ret := storeRequest(t, srv, http.MethodGet, "api/admin/audit?action=update", nil)
+	entry := ret["entries"].([]any)[0].(map[string]any)
+	assert.Equal(t, "alice", entry["principal"])
+	assert.Equal(t, "127.0.0.1", entry["remote"])
+	assert.NotEmpty(t, entry["time"])
+	assert.Equal(t, map[string]any{"title": []any{"Полить цветы", "Полить фикус"}}, entry["diff"])
+
+	ret = storeRequest(t, srv, http.MethodGet, "api/admin/audit?action=delete", nil)
+	diff := ret["entries"].([]any)[0].(map[string]any)["diff"].(map[string]any)
+	assert.Equal(t, []any{"Полить фикус", nil}, diff["title"])
+
+	// выгрузка JSON Lines — от старых к новым
+	resp, err := srv.Client().Get(srv.URL + "/api/admin/audit?format=jsonl")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var actions []string
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		var e map[string]any
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &e), sc.Text())
+		actions = append(actions, e["action"].(string))
+	}
+	assert.Equal(t, []string{"create", "update", "done", "delete", "restore"}, actions)
+
+	for _, params := range []string{"task_id=x", "from=2024", "format=xml"} {
+		ret := storeRequest(t, srv, http.MethodGet, "api/admin/audit?"+params, nil)
+		assert.NotEmpty(t, ret["error"], params)
+	}
+
+	// журнал читают только администраторы из TODO_ADMINS — ни обычный
+	// пользователь, ни вошедший по общему паролю
+	for _, client := range []*http.Client{bob, signinAs(t, srv, "", "secret")} {
+		resp, err = client.Get(srv.URL + "/api/admin/audit")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	}
+	resp, err = bob.Post(srv.URL+"/api/task/done?id="+id, "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	ret = storeRequest(t, srv, http.MethodGet, "api/admin/audit?limit=1", nil)
+	assert.Equal(t, "bob", ret["entries"].([]any)[0].(map[string]any)["principal"])
+
+	// без аутентификации изменения записываются от имени anonymous
+	open := newStoreServer(t, store)
+	storeRequest(t, open, http.MethodDelete, "api/task?id="+id, nil)
+	ret = storeRequest(t, open, http.MethodGet, "api/admin/audit?limit=1", nil)
+	assert.Equal(t, "anonymous", ret["entries"].([]any)[0].(map[string]any)["principal"])
+}
+
+// failingLog — хранилище, в котором не удаётся записать журнал и историю.
+type failingLog struct{ db.TaskStore }
+
+func (failingLog) AddAudit(*db.AuditEntry) error      { return errors.New("audit log is full") }
+func (failingLog) AddCompletion(*db.Completion) error { return errors.New("history is full") }
+
+// TestAuditFailure проверяет, что сохранённое изменение не становится ошибкой,
+// если после него не удалось записать журнал или историю.
+func TestAuditFailure(t *testing.T) {
+	store := db.NewMemoryStore()
+	srv := newStoreServer(t, failingLog{store})
+	date := time.Now().Format(`20060102`)
+	id := storeAddTask(t, srv, map[string]any{"date": date, "title": "Полить цветы", "repeat": "d 2"})
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+id, nil))
+	task, err := store.GetTask(id)
+	require.NoError(t, err)
+	assert.NotEqual(t, date, task.Date)
+
+	ret := storeRequest(t, srv, http.MethodPost, "api/projects", map[string]any{"name": "Дача"})
+	project, _ := ret["id"].(string)
+	require.NotEmpty(t, project, ret)
+	assert.Empty(t, storeRequest(t, srv, http.MethodDelete, "api/projects?id="+project, nil))
+}
+
+func TestAudit(t *testing.T) {
+	forEachStore(t, checkAudit)
+}
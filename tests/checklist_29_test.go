@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/db"
+)
+
+// checklistState возвращает пункты чек-листа задачи из ответа /api/task
+// в виде "title:+" (выполнен) или "title:-".
+func checklistState(ret map[string]any) []string {
+	var out []string
+	items, _ := ret["checklist"].([]any)
+	for _, it := range items {
+		m := it.(map[string]any)
+		state := m["title"].(string) + ":-"
+		if m["done"].(bool) {
+			state = m["title"].(string) + ":+"
+		}
+		out = append(out, state)
+	}
+	return out
+}
+
+// checkChecklist проверяет чек-листы задач через API поверх store.
+func checkChecklist(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	date := time.Now().Format(`20060102`)
+	id := storeAddTask(t, srv, map[string]any{
+		"date": date, "title": "Уборка", "repeat": "d 7",
+		"checklist": []map[string]any{{"title": " Пропылесосить "}, {"title": "Полить цветы", "done": true}, {"title": "Вынести мусор"}},
+	})
+	ret := storeRequest(t, srv, http.MethodPost, "api/task", map[string]any{
+		"date": date, "title": "x", "checklist": []map[string]any{{"title": " "}},
+	})
+	assert.NotEmpty(t, ret["error"])
+
+	task := storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.Equal(t, []string{"Пропылесосить:-", "Полить цветы:+", "Вынести мусор:-"}, checklistState(task))
+	assert.Equal(t, "1/3", task["progress"])
+	items := task["checklist"].([]any)
+	first := items[0].(map[string]any)["id"].(string)
+
+	// в списке — только счётчик
+	ret = storeRequest(t, srv, http.MethodGet, "api/tasks", nil)
+	list := ret["tasks"].([]any)
+	require.Len(t, list, 1)
+	assert.Equal(t, "1/3", list[0].(map[string]any)["progress"])
+	assert.Nil(t, list[0].(map[string]any)["checklist"])
+
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/checklist?id="+id+"&item="+first, nil))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPost, "api/task/checklist?id="+id+"&item=999999", nil)["error"])
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPost, "api/task/checklist?id="+id+"&item="+first+"&done=maybe", nil)["error"])
+	task = storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.Equal(t, "2/3", task["progress"])
+
+	// PUT без checklist его сохраняет; пункты с прежним id остаются теми же
+	storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": id, "title": "Уборка дома"})
+	task = storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.Equal(t, []string{"Пропылесосить:+", "Полить цветы:+", "Вынести мусор:-"}, checklistState(task))
+	assert.Empty(t, storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{
+		"id": id, "checklist": []map[string]any{{"id": first, "title": "Пропылесосить", "done": true}, {"title": "Помыть пол"}},
+	}))
+	task = storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.Equal(t, []string{"Пропылесосить:+", "Помыть пол:-"}, checklistState(task))
+	assert.Equal(t, first, task["checklist"].([]any)[0].(map[string]any)["id"])
+
+	// выполнение повторяющейся задачи начинает чек-лист заново
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+id, nil))
+	task = storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.Equal(t, []string{"Пропылесосить:-", "Помыть пол:-"}, checklistState(task))
+	assert.Equal(t, "0/2", task["progress"])
+
+	ret = storeRequest(t, srv, http.MethodGet, "api/admin/audit?action=check", nil)
+	entries := ret["entries"].([]any)
+	require.Len(t, entries, 1)
+	diff := entries[0].(map[string]any)["diff"].(map[string]any)
+	assert.Contains(t, diff, "checklist")
+	assert.NotContains(t, diff, "progress")
+
+	// пустой список чек-лист снимает
+	assert.Empty(t, storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": id, "checklist": []any{}}))
+	task = storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.Nil(t, task["checklist"])
+	assert.Nil(t, task["progress"])
+}
+
+func TestChecklist(t *testing.T) {
+	forEachStore(t, checkChecklist)
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateCron(t *testing.T) {
+	tbl := []nextDate{
+		{"20240101", "cron 0 9 1,15 * *", "20240201"},
+		{"20240101", "cron 0 9 * * MON-FRI", "20240129"},
+		{"20240101", "cron 0 9 1,15 * 1-5", "20240129"},
+		{"20240101", "cron 30 18 * * 0", "20240128"},
+		{"20240101", "cron 30 18 * * 7", "20240128"},
+		{"20240101", "cron 0 0 */10 * *", "20240131"},
+		{"20240101", "cron 0 0 1 jan,jul *", "20240701"},
+		{"20240101", "cron 0 0 1 */3 *", "20240401"},
+		{"20240101", "cron @monthly", "20240201"},
+		{"20240101", "cron 0 0 31 2 *", ""},
+		{"20240101", "cron 60 0 * * *", ""},
+		{"20240101", "cron 0 0 * 13 *", ""},
+		{"20240101", "cron 0 0 * *", ""},
+		{"20240101", "cron 0 0 5-1 * *", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+}
+
+func TestDoneCron(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	id := addTask(t, task{
+		title:  "Выгрузить отчёт",
+		repeat: "cron 0 9 * * *",
+	})
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Now().AddDate(0, 0, 1).Format(`20060102`), task.Date)
+}
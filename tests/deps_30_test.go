@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/db"
+)
+
+// blockedTitles возвращает title задач /api/tasks с флагом blocked.
+func blockedTitles(t *testing.T, srv *httptest.Server) []string {
+	ret := storeRequest(t, srv, http.MethodGet, "api/tasks", nil)
+	require.Nil(t, ret["error"], ret)
+	titles := []string{}
+	for _, it := range ret["tasks"].([]any) {
+		m := it.(map[string]any)
+		if m["blocked"] == true {
+			titles = append(titles, m["title"].(string))
+		}
+	}
+	return titles
+}
+
+// checkDeps проверяет зависимости между задачами через API поверх store.
+func checkDeps(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	date := time.Now().Format(`20060102`)
+	build := storeAddTask(t, srv, map[string]any{"date": date, "title": "Сборка"})
+	tests := storeAddTask(t, srv, map[string]any{"date": date, "title": "Тесты", "blocked_by": []string{build}})
+	release := storeAddTask(t, srv, map[string]any{"date": date, "title": "Релиз", "blocked_by": []string{tests, build, tests}})
+
+	ret := storeRequest(t, srv, http.MethodGet, "api/task?id="+release, nil)
+	assert.Equal(t, []any{build, tests}, ret["blocked_by"])
+	assert.Equal(t, true, ret["blocked"])
+	assert.Equal(t, []string{"Тесты", "Релиз"}, blockedTitles(t, srv))
+
+	for _, values := range []map[string]any{
+		{"date": date, "title": "x", "blocked_by": []string{"999999"}},
+		{"date": date, "title": "x", "blocked_by": []string{"abc"}},
+	} {
+		ret = storeRequest(t, srv, http.MethodPost, "api/task", values)
+		assert.NotEmpty(t, ret["error"], values)
+	}
+	// циклы: сборка не может ждать релиза, задача — саму себя
+	ret = storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": build, "blocked_by": []string{release}})
+	assert.Equal(t, "dependency cycle", ret["error"])
+	ret = storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": build, "blocked_by": []string{build}})
+	assert.Equal(t, "dependency cycle", ret["error"])
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+build, nil)
+	assert.Nil(t, ret["blocked_by"])
+
+	// заблокированную задачу без force=1 выполнить нельзя
+	ret = storeRequest(t, srv, http.MethodPost, "api/task/done?id="+tests, nil)
+	assert.Equal(t, "task is blocked", ret["error"])
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+build, nil))
+	assert.Equal(t, []string{"Релиз"}, blockedTitles(t, srv))
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+tests, nil))
+	assert.Empty(t, blockedTitles(t, srv))
+
+	// задача из корзины снова блокирует, если её вернуть; удалённая навсегда —
+	// пропадает из зависимостей
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/trash/restore?id="+tests, nil))
+	assert.Equal(t, []string{"Релиз"}, blockedTitles(t, srv))
+	ret = storeRequest(t, srv, http.MethodPost, "api/task", map[string]any{"date": date, "title": "x", "blocked_by": []string{build}})
+	assert.NotEmpty(t, ret["error"], "задача в корзине не может блокировать новую")
+	assert.Empty(t, storeRequest(t, srv, http.MethodDelete, "api/task?id="+tests, nil))
+	assert.Empty(t, storeRequest(t, srv, http.MethodDelete, "api/trash?id="+tests, nil))
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+release, nil)
+	assert.Equal(t, []any{build}, ret["blocked_by"])
+	assert.Nil(t, ret["blocked"])
+
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+release, nil))
+}
+
+// checkForcedDone проверяет выполнение заблокированной задачи с force=1.
+func checkForcedDone(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	date := time.Now().Format(`20060102`)
+	a := storeAddTask(t, srv, map[string]any{"date": date, "title": "A"})
+	b := storeAddTask(t, srv, map[string]any{"date": date, "title": "B", "blocked_by": []string{a}})
+
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+b, nil)["error"])
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+b+"&force=1", nil))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodGet, "api/task?id="+b, nil)["error"])
+}
+
+// checkRecurringBlocker проверяет повторяющуюся блокирующую задачу: её
+// выполненное повторение снимает блокировку, хотя задача остаётся в списке.
+func checkRecurringBlocker(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	date := time.Now().Format(`20060102`)
+	meeting := storeAddTask(t, srv, map[string]any{"date": date, "title": "Планёрка", "repeat": "d 7"})
+	minutes := storeAddTask(t, srv, map[string]any{"date": date, "title": "Протокол", "blocked_by": []string{meeting}})
+	assert.Equal(t, []string{"Протокол"}, blockedTitles(t, srv))
+
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+meeting, nil))
+	assert.Empty(t, blockedTitles(t, srv))
+	ret := storeRequest(t, srv, http.MethodGet, "api/task?id="+meeting, nil)
+	require.Nil(t, ret["error"], ret)
+
+	// зависимость, добавленная после выполнения, ждёт следующего повторения;
+	// у прежней отметка о выполнении при замене списка сохраняется
+	storeAddTask(t, srv, map[string]any{"date": date, "title": "Отчёт", "blocked_by": []string{meeting}})
+	assert.Empty(t, storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": minutes, "title": "Протокол планёрки"}))
+	assert.Equal(t, []string{"Отчёт"}, blockedTitles(t, srv))
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+meeting, nil))
+	assert.Empty(t, blockedTitles(t, srv))
+}
+
+// checkDeletedBlocker проверяет, что удаление блокирующей задачи в корзину
+// не считается её выполнением.
+func checkDeletedBlocker(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	date := time.Now().Format(`20060102`)
+	build := storeAddTask(t, srv, map[string]any{"date": date, "title": "Сборка"})
+	release := storeAddTask(t, srv, map[string]any{"date": date, "title": "Релиз", "blocked_by": []string{build}})
+
+	assert.Empty(t, storeRequest(t, srv, http.MethodDelete, "api/task?id="+build, nil))
+	assert.Equal(t, []string{"Релиз"}, blockedTitles(t, srv))
+	ret := storeRequest(t, srv, http.MethodPost, "api/task/done?id="+release, nil)
+	assert.Equal(t, "task is blocked", ret["error"])
+	// задача из корзины остаётся в blocked_by при изменении зависимой
+	assert.Empty(t, storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": release, "title": "Релиз 1.0"}))
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+release, nil)
+	assert.Equal(t, []any{build}, ret["blocked_by"])
+	assert.Equal(t, true, ret["blocked"])
+
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/trash/restore?id="+build, nil))
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+build, nil))
+	assert.Empty(t, blockedTitles(t, srv))
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+release, nil))
+}
+
+func TestDeps(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store db.TaskStore) {
+		checkDeps(t, store)
+		checkForcedDone(t, store)
+		checkRecurringBlocker(t, store)
+		checkDeletedBlocker(t, store)
+	})
+}
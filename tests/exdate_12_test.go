@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExdates(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	date := now.AddDate(0, 0, 7).Format(`20060102`)
+	skip := now.AddDate(0, 0, 14).Format(`20060102`)
+
+	id := addTaskValues(t, map[string]any{
+		"date":   date,
+		"title":  "Планёрка",
+		"repeat": "d 7",
+	})
+
+	// исключаем следующее после текущей даты повторение
+	ret, err := postJSON("api/task/exdate?id="+id+"&date="+skip, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, []any{skip}, m["exdates"])
+
+	// отметка о выполнении перескакивает через исключённую дату
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 21).Format(`20060102`), task.Date)
+
+	// исключение текущей даты сразу переносит задачу
+	cur := task.Date
+	ret, err = postJSON("api/task/exdate?id="+id+"&date="+cur, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 28).Format(`20060102`), task.Date)
+
+	ret, err = postJSON("api/task/exdate?id="+id+"&date="+skip, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task/exdate?id="+id+"&date="+skip, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = postJSON("api/task/exdate?id="+id+"&date=oops", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	get, err := getBody("api/nextdate?now=20240126&date=20240120&repeat=d+7&exdates=20240127")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240203", strings.TrimSpace(string(get)))
+
+	// ограничения серии действуют и при исключении: последнее повторение
+	// исключить нельзя, задача остаётся на своей дате
+	for _, values := range []map[string]any{
+		{"date": date, "title": "До даты", "repeat": "d 1", "repeat_until": date},
+		{"date": date, "title": "Один раз", "repeat": "d 1", "repeat_count": "1"},
+	} {
+		id := addTaskValues(t, values)
+		ret, err = postJSON("api/task/exdate?id="+id+"&date="+date, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, "no further occurrences", ret["error"], values["title"])
+		body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+		assert.NoError(t, err)
+		m = nil
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Equal(t, date, m["date"])
+		assert.Nil(t, m["exdates"])
+	}
+}
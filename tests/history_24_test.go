@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/db"
+)
+
+// historyEntries возвращает записи истории как "title date" в порядке выдачи.
+func historyEntries(t *testing.T, srv *httptest.Server, params string) []string {
+	ret := storeRequest(t, srv, http.MethodGet, "api/history?"+params, nil)
+	require.Nil(t, ret["error"], ret)
+	list, _ := ret["history"].([]any)
+	out := []string{}
+	for _, it := range list {
+		m := it.(map[string]any)
+		assert.NotEmpty(t, m["completed"])
+		out = append(out, m["title"].(string)+" "+m["date"].(string))
+	}
+	return out
+}
+
+// checkHistory проверяет историю выполнения через API поверх store.
+func checkHistory(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	now := time.Now()
+	today, tomorrow := now.Format(`20060102`), now.AddDate(0, 0, 1).Format(`20060102`)
+	once := storeAddTask(t, srv, map[string]any{"date": today, "title": "Разовая"})
+	daily := storeAddTask(t, srv, map[string]any{"date": today, "title": "Зарядка", "repeat": "d 1"})
+
+	for _, id := range []string{once, daily, daily} {
+		assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+id, nil))
+	}
+	// в истории — название на момент выполнения, и она переживает удаление задачи
+	storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": daily, "title": "Пробежка"})
+	storeRequest(t, srv, http.MethodDelete, "api/trash?all=1", nil)
+
+	assert.Equal(t, []string{"Зарядка " + tomorrow, "Зарядка " + today, "Разовая " + today},
+		historyEntries(t, srv, ""))
+	assert.Equal(t, []string{"Зарядка " + tomorrow, "Зарядка " + today},
+		historyEntries(t, srv, "task_id="+daily))
+	assert.Equal(t, []string{"Разовая " + today}, historyEntries(t, srv, "task_id="+once+"&from="+today+"&to="+today))
+	assert.Equal(t, []string{"Зарядка " + tomorrow}, historyEntries(t, srv, "limit=1"))
+	assert.Empty(t, historyEntries(t, srv, "from="+tomorrow))
+	assert.Empty(t, historyEntries(t, srv, "to="+now.AddDate(0, 0, -1).Format(`20060102`)))
+
+	for _, params := range []string{"task_id=abc", "from=2024", "to=01.01.2024", "from=" + tomorrow + "&to=" + today} {
+		ret := storeRequest(t, srv, http.MethodGet, "api/history?"+params, nil)
+		assert.NotEmpty(t, ret["error"], params)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	forEachStore(t, checkHistory)
+}
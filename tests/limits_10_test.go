@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addTaskValues(t *testing.T, values map[string]any) string {
+	ret, err := postJSON("api/task", values, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["id"], "%v", ret)
+	id, _ := ret["id"].(string)
+	return id
+}
+
+func TestRepeatLimits(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+
+	// два повторения: после второй отметки задача удаляется
+	id := addTaskValues(t, map[string]any{
+		"title":        "Курс из двух занятий",
+		"repeat":       "d 1",
+		"repeat_count": "2",
+	})
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), task.Date)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	// дата окончания: следующее повторение позже until — задача удаляется
+	id = addTaskValues(t, map[string]any{
+		"title":        "До конца недели",
+		"repeat":       "d 3",
+		"repeat_until": now.AddDate(0, 0, 4).Format(`20060102`),
+	})
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), task.Date)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	// ограничения без правила повторения — ошибка
+	ret, err = postJSON("api/task", map[string]any{
+		"title":        "Без повтора",
+		"repeat_count": "3",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
+
+func TestNextDateLimits(t *testing.T) {
+	get, err := getBody("api/nextdate?now=20240126&date=20240120&repeat=d+7&until=20240131")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240127", strings.TrimSpace(string(get)))
+
+	get, err = getBody("api/nextdate?now=20240126&date=20240120&repeat=d+7&until=20240126")
+	assert.NoError(t, err)
+	assert.Contains(t, string(get), "no further occurrences")
+
+	get, err = getBody("api/nextdate?now=20240126&date=20240120&repeat=d+7&count=1")
+	assert.NoError(t, err)
+	assert.Contains(t, string(get), "no further occurrences")
+
+	resp, err := http.Get(getURL("api/nextdate?now=20240126&date=20240120&repeat=d+7&count=1"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "application/json; charset=UTF-8", resp.Header.Get("Content-Type"))
+}
@@ -0,0 +1,25 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	// все версии от 1 до последней применены ровно по одному разу
+	var count, latest int
+	err := db.QueryRow(`SELECT count(*), COALESCE(MAX(version), 0) FROM schema_migrations`).
+		Scan(&count, &latest)
+	assert.NoError(t, err)
+	assert.Greater(t, latest, 0)
+	assert.Equal(t, latest, count)
+
+	var n int
+	err = db.Get(&n, `SELECT count(*) FROM pragma_table_info('task_repeat') WHERE name = 'repeat_from'`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
@@ -77,3 +77,40 @@ func TestNextDate(t *testing.T) {
 	}
 	check()
 }
+
+// TestNextDateMonthly — случаи правила m, которых нет в общей таблице:
+// дни, которых нет в коротких месяцах, и отсчёт с конца февраля
+// в високосный и обычный год.
+func TestNextDateMonthly(t *testing.T) {
+	tbl := []struct {
+		now string
+		nextDate
+	}{
+		{"20240201", nextDate{"20240131", "m 31", "20240331"}},
+		{"20240201", nextDate{"20240130", "m 30", "20240330"}},
+		{"20230201", nextDate{"20230129", "m 29", "20230329"}},
+		{"20240201", nextDate{"20240129", "m 29", "20240229"}},
+		{"20240101", nextDate{"20240101", "m 29 2", "20240229"}},
+		{"20240301", nextDate{"20240229", "m 29 2", "20280229"}},
+		{"20240227", nextDate{"20240227", "m -1,-2", "20240228"}},
+		{"20240228", nextDate{"20240228", "m -1,-2", "20240229"}},
+		{"20230226", nextDate{"20230226", "m -1,-2", "20230227"}},
+		{"20230227", nextDate{"20230227", "m -1,-2", "20230228"}},
+		{"20240229", nextDate{"20240229", "m -1", "20240331"}},
+		{"20241220", nextDate{"20241206", "m 5", "20250105"}},
+		{"20240101", nextDate{"20240101", "m 31 4,6,9,11", ""}},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=%s&date=%s&repeat=%s",
+			v.now, url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q, %q}`,
+			v.now, v.date, v.repeat, v.want)
+	}
+}
@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getOccurrences(t *testing.T, query string) map[string]any {
+	body, err := getBody("api/occurrences?" + query)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m
+}
+
+func TestOccurrences(t *testing.T) {
+	m := getOccurrences(t, "now=20240126&date=20240120&repeat=d+7")
+	assert.Equal(t, []any{"20240127", "20240203", "20240210", "20240217", "20240224"}, m["dates"])
+
+	m = getOccurrences(t, "now=20240126&date=20240101&repeat=w+1,4&n=3")
+	assert.Equal(t, []any{"20240129", "20240201", "20240205"}, m["dates"])
+
+	m = getOccurrences(t, "now=20240126&date=20240101&repeat=m+-1&to=20240501")
+	assert.Equal(t, []any{"20240131", "20240229", "20240331", "20240430"}, m["dates"])
+
+	m = getOccurrences(t, "now=20240126&date=20240101&repeat="+
+		url.QueryEscape("RRULE:FREQ=MONTHLY;BYDAY=2TU;COUNT=4"))
+	assert.Equal(t, []any{"20240213", "20240312"}, m["dates"])
+
+	m = getOccurrences(t, "now=20240126&date=20240120&repeat=d+7&count=3")
+	assert.Equal(t, []any{"20240127", "20240203"}, m["dates"])
+
+	m = getOccurrences(t, "now=20240126&date=20240120&repeat=d+7&n=1000")
+	assert.NotEmpty(t, m["error"])
+
+	m = getOccurrences(t, "now=20240126&date=20240120&repeat=ooops")
+	assert.NotEmpty(t, m["error"])
+}
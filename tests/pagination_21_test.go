@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/db"
+)
+
+// listPages проходит список /api/tasks по курсорам и возвращает title всех
+// задач в порядке выдачи; before вызывается перед запросом каждой следующей страницы.
+func listPages(t *testing.T, srv *httptest.Server, params url.Values, before func()) []string {
+	var titles []string
+	for page := 0; ; page++ {
+		require.Less(t, page, 20, "pagination does not stop")
+		ret := storeRequest(t, srv, http.MethodGet, "api/tasks?"+params.Encode(), nil)
+		require.Nil(t, ret["error"], ret)
+		list, _ := ret["tasks"].([]any)
+		for _, it := range list {
+			titles = append(titles, it.(map[string]any)["title"].(string))
+		}
+		next, _ := ret["next_cursor"].(string)
+		if next == "" {
+			return titles
+		}
+		if before != nil {
+			before()
+		}
+		params.Set("cursor", next)
+	}
+}
+
+// checkPagination проверяет сортировку и постраничную выдачу через API поверх store.
+func checkPagination(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	day := func(n int) string { return time.Now().AddDate(0, 0, n).Format(`20060102`) }
+	storeAddTask(t, srv, map[string]any{"date": day(3), "title": "c", "comment": "план"})
+	storeAddTask(t, srv, map[string]any{"date": day(1), "title": "e", "comment": "план"})
+	storeAddTask(t, srv, map[string]any{"date": day(2), "title": "a", "comment": "план"})
+	storeAddTask(t, srv, map[string]any{"date": day(1), "title": "d", "comment": "план"})
+	storeAddTask(t, srv, map[string]any{"date": day(2), "title": "b", "comment": "план"})
+
+	// по умолчанию — по дате, при равных датах — по id; задачи, добавленные
+	// между страницами, не сдвигают выдачу
+	n := 0
+	titles := listPages(t, srv, url.Values{"limit": {"2"}}, func() {
+		n++
+		storeAddTask(t, srv, map[string]any{"date": day(0), "title": "new", "comment": "план"})
+	})
+	assert.Equal(t, []string{"e", "d", "a", "b", "c"}, titles)
+	assert.Equal(t, 2, n)
+
+	titles = listPages(t, srv, url.Values{"limit": {"3"}, "sort": {"-title"}, "search": {"-new"}}, nil)
+	assert.Equal(t, []string{"e", "d", "c", "b", "a"}, titles)
+	titles = listPages(t, srv, url.Values{"limit": {"4"}, "sort": {"id"}}, nil)
+	assert.Equal(t, []string{"c", "e", "a", "d", "b", "new", "new"}, titles)
+	titles = listPages(t, srv, url.Values{"limit": {"2"}, "sort": {"-created"}, "search": {"repeat:no -new"}}, nil)
+	assert.Equal(t, []string{"b", "d", "a", "e", "c"}, titles)
+
+	// при поиске без sort — по релевантности
+	titles = listPages(t, srv, url.Values{"limit": {"1"}, "search": {"план"}}, nil)
+	assert.Len(t, titles, 7)
+
+	ret := storeRequest(t, srv, http.MethodGet, "api/tasks?limit=2&total=1&search=-new", nil)
+	assert.Equal(t, float64(5), ret["total"])
+	assert.Len(t, ret["tasks"], 2)
+	cursor, _ := ret["next_cursor"].(string)
+	require.NotEmpty(t, cursor)
+
+	ret = storeRequest(t, srv, http.MethodGet, "api/tasks", nil)
+	assert.NotContains(t, ret, "total")
+	assert.NotContains(t, ret, "next_cursor")
+
+	for _, path := range []string{
+		"api/tasks?sort=color",
+		"api/tasks?cursor=%21%21",
+		"api/tasks?cursor=" + cursor,                        // другой search
+		"api/tasks?search=-new&sort=title&cursor=" + cursor, // другая сортировка
+	} {
+		ret = storeRequest(t, srv, http.MethodGet, path, nil)
+		assert.NotEmpty(t, ret["error"], path)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	forEachStore(t, checkPagination)
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRepeatPhrase(t *testing.T) {
+	tbl := []struct {
+		text string
+		want string
+	}{
+		{"every day", "d 1"},
+		{"каждые 3 дня", "d 3"},
+		{"каждый понедельник", "w 1"},
+		{"every other Friday", "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=FR"},
+		{"every 2 weeks on Mon, Thu", "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"},
+		{"по будням", "w 1,2,3,4,5"},
+		{"каждый рабочий день", "b 1"},
+		{"last day of month", "m -1"},
+		{"15 числа каждого месяца", "m 15"},
+		{"второй вторник месяца", "RRULE:FREQ=MONTHLY;BYDAY=2TU"},
+		{"ежегодно", "y"},
+		{"every 3rd working day", "b 3"},
+		{"каждый 3-й рабочий день", "b 3"},
+		{"every 2nd day", "d 2"},
+		{"every weekday except friday", "w 1,2,3,4"},
+		{"каждые две недели", "d 14"},
+		{"как-нибудь потом", ""},
+		{"every 500 days", ""},
+		{"twice a week", ""},
+		{"every day at noon", ""},
+		{"каждый 3-й месяц", ""},
+	}
+	for _, v := range tbl {
+		body, err := getBody("api/repeat/parse?text=" + url.QueryEscape(v.text))
+		assert.NoError(t, err)
+		var m map[string]string
+		assert.NoError(t, json.Unmarshal(body, &m))
+		if v.want == "" {
+			assert.NotEmpty(t, m["error"], v.text)
+			continue
+		}
+		assert.Equal(t, v.want, m["repeat"], v.text)
+	}
+}
+
+func TestAddTaskPhrase(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	ret, err := postJSON("api/task?parse_repeat=1", map[string]any{
+		"title":  "Йога",
+		"repeat": "каждую среду и пятницу",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["id"])
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, ret["id"])
+	assert.NoError(t, err)
+	assert.Equal(t, "w 3,5", task.Repeat)
+
+	// каноническое правило с флагом не меняется
+	ret, err = postJSON("api/task?parse_repeat=1", map[string]any{
+		"title":  "Отчёт",
+		"repeat": "d 7",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, ret["id"])
+	assert.NoError(t, err)
+	assert.Equal(t, "d 7", task.Repeat)
+
+	// без флага фраза — ошибка
+	ret, err = postJSON("api/task", map[string]any{
+		"title":  "Йога",
+		"repeat": "каждую среду",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/db"
+)
+
+// checkPriority проверяет приоритеты задач и сортировку по ним через API поверх store.
+func checkPriority(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	day := func(n int) string { return time.Now().AddDate(0, 0, n).Format(`20060102`) }
+	storeAddTask(t, srv, map[string]any{"date": day(1), "title": "a"})
+	storeAddTask(t, srv, map[string]any{"date": day(1), "title": "b", "priority": "Urgent"})
+	storeAddTask(t, srv, map[string]any{"date": day(1), "title": "c", "priority": "low"})
+	low := storeAddTask(t, srv, map[string]any{"date": day(2), "title": "d", "priority": "none"})
+	storeAddTask(t, srv, map[string]any{"date": day(2), "title": "e", "priority": "high"})
+	storeAddTask(t, srv, map[string]any{"date": day(1), "title": "f", "priority": "high"})
+
+	ret := storeRequest(t, srv, http.MethodPost, "api/task", map[string]any{"date": day(1), "title": "x", "priority": "asap"})
+	assert.NotEmpty(t, ret["error"])
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+low, nil)
+	assert.Nil(t, ret["priority"])
+
+	// по умолчанию — по дате, в один день сначала важные
+	assert.Equal(t, []string{"b", "f", "c", "a", "e", "d"}, listPages(t, srv, url.Values{"limit": {"4"}}, nil))
+	assert.Equal(t, []string{"d", "e", "a", "c", "f", "b"}, listPages(t, srv, url.Values{"limit": {"4"}, "sort": {"-date"}}, nil))
+	// по приоритету, при равном — по дате
+	assert.Equal(t, []string{"b", "f", "e", "c", "a", "d"}, listPages(t, srv, url.Values{"limit": {"2"}, "sort": {"priority"}}, nil))
+	assert.Equal(t, []string{"d", "a", "c", "e", "f", "b"}, listPages(t, srv, url.Values{"limit": {"5"}, "sort": {"-priority"}}, nil))
+
+	// PUT без priority его сохраняет, с новым — меняет
+	storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": low, "title": "d2"})
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+low, nil)
+	assert.Nil(t, ret["priority"])
+	assert.Empty(t, storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": low, "priority": "URGENT"}))
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+low, nil)
+	assert.Equal(t, "urgent", ret["priority"])
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": low, "priority": "max"})["error"])
+	assert.Equal(t, []string{"b", "d2", "f", "e"}, listPages(t, srv, url.Values{"limit": {"4"}, "sort": {"priority"}}, nil)[:4])
+
+	// в повестке дня сначала важные задачи
+	ret = storeRequest(t, srv, http.MethodGet, "api/agenda?from="+day(2)+"&to="+day(2), nil)
+	days := ret["days"].([]any)
+	require.Len(t, days, 1)
+	var titles []string
+	for _, it := range days[0].(map[string]any)["tasks"].([]any) {
+		titles = append(titles, it.(map[string]any)["title"].(string))
+	}
+	assert.Equal(t, []string{"d2", "e"}, titles)
+}
+
+func TestPriority(t *testing.T) {
+	forEachStore(t, checkPriority)
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/db"
+)
+
+// projectTitles возвращает title задач /api/tasks с параметрами query.
+func projectTitles(t *testing.T, srv *httptest.Server, query string) []string {
+	ret := storeRequest(t, srv, http.MethodGet, "api/tasks?"+query, nil)
+	require.Nil(t, ret["error"], ret)
+	titles := []string{}
+	for _, it := range ret["tasks"].([]any) {
+		titles = append(titles, it.(map[string]any)["title"].(string))
+	}
+	return titles
+}
+
+// projectNames возвращает имена проектов /api/projects с параметрами query.
+func projectNames(t *testing.T, srv *httptest.Server, query string) []string {
+	ret := storeRequest(t, srv, http.MethodGet, "api/projects?"+query, nil)
+	require.Nil(t, ret["error"], ret)
+	names := []string{}
+	for _, it := range ret["projects"].([]any) {
+		names = append(names, it.(map[string]any)["name"].(string))
+	}
+	return names
+}
+
+// checkProjects проверяет проекты и задачи в них через API поверх store.
+func checkProjects(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	date := time.Now().Format(`20060102`)
+
+	assert.Equal(t, []string{"Входящие"}, projectNames(t, srv, ""))
+	ret := storeRequest(t, srv, http.MethodPost, "api/projects", map[string]any{"name": " Работа ", "color": "#3366FF"})
+	work, _ := ret["id"].(string)
+	require.NotEmpty(t, work, ret)
+	ret = storeRequest(t, srv, http.MethodPost, "api/projects", map[string]any{"name": "Дом"})
+	home, _ := ret["id"].(string)
+	require.NotEmpty(t, home, ret)
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPost, "api/projects", map[string]any{"name": "Дом"})["error"])
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPost, "api/projects", map[string]any{"name": "x", "color": "red"})["error"])
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPost, "api/projects", map[string]any{"name": " "})["error"])
+
+	inbox := storeAddTask(t, srv, map[string]any{"date": date, "title": "Разобрать почту"})
+	deploy := storeAddTask(t, srv, map[string]any{"date": date, "title": "Выкатка", "project_id": work})
+	storeAddTask(t, srv, map[string]any{"date": date, "title": "Уборка", "project_id": home})
+	ret = storeRequest(t, srv, http.MethodPost, "api/task", map[string]any{"date": date, "title": "x", "project_id": "999"})
+	assert.NotEmpty(t, ret["error"])
+
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+inbox, nil)
+	assert.Equal(t, "1", ret["project_id"])
+	ret = storeRequest(t, srv, http.MethodGet, "api/projects?id="+work, nil)
+	assert.Equal(t, map[string]any{"id": work, "name": "Работа", "color": "#3366ff", "archived": false, "tasks": float64(1)}, ret)
+
+	assert.Equal(t, []string{"Выкатка"}, projectTitles(t, srv, "project="+work))
+	assert.Len(t, projectTitles(t, srv, ""), 3)
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodGet, "api/tasks?project=abc", nil)["error"])
+
+	// перенос задачи — PUT с project_id, остальные поля сохраняются
+	assert.Empty(t, storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": inbox, "project_id": work}))
+	assert.Equal(t, []string{"Выкатка", "Разобрать почту"}, projectTitles(t, srv, "project="+work+"&sort=title"))
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+inbox, nil)
+	assert.Equal(t, "Разобрать почту", ret["title"])
+
+	// архивный проект скрыт из общего списка и не принимает задачи
+	assert.Empty(t, storeRequest(t, srv, http.MethodPut, "api/projects", map[string]any{"id": home, "archived": true}))
+	assert.Equal(t, []string{"Входящие", "Работа"}, projectNames(t, srv, ""))
+	assert.Equal(t, []string{"Входящие", "Работа", "Дом"}, projectNames(t, srv, "archived=1"))
+	assert.Equal(t, []string{"Выкатка", "Разобрать почту"}, projectTitles(t, srv, "sort=title"))
+	assert.Equal(t, []string{"Уборка"}, projectTitles(t, srv, "project="+home))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPost, "api/task", map[string]any{"date": date, "title": "x", "project_id": home})["error"])
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": deploy, "project_id": home})["error"])
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPut, "api/projects", map[string]any{"id": "1", "archived": true})["error"])
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPut, "api/projects", map[string]any{"id": home, "name": "Работа"})["error"])
+
+	// удаление проекта переносит его задачи во «Входящие»
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodDelete, "api/projects?id=1", nil)["error"])
+	assert.Empty(t, storeRequest(t, srv, http.MethodDelete, "api/projects?id="+work, nil))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodDelete, "api/projects?id="+work, nil)["error"])
+	assert.Equal(t, []string{"Выкатка", "Разобрать почту"}, projectTitles(t, srv, "project=1&sort=title"))
+	ret = storeRequest(t, srv, http.MethodGet, "api/admin/audit?action=project_delete", nil)
+	entries := ret["entries"].([]any)
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]any{"project": []any{"Работа", nil}}, entries[0].(map[string]any)["diff"])
+}
+
+func TestProjects(t *testing.T) {
+	forEachStore(t, checkProjects)
+}
+
+// у задач из старых БД нет строки task_meta — они во «Входящих» и переносятся так же
+func TestProjectsLegacyRows(t *testing.T) {
+	sqlite, path := openTestSQLite(t)
+	raw, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer raw.Close()
+	res, err := raw.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES ('20240101', 'Старая', '', '')`)
+	require.NoError(t, err)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+
+	srv := newStoreServer(t, sqlite)
+	ret := storeRequest(t, srv, http.MethodGet, "api/task?id="+strconv.FormatInt(id, 10), nil)
+	assert.Equal(t, "1", ret["project_id"])
+	ret = storeRequest(t, srv, http.MethodPost, "api/projects", map[string]any{"name": "Архив"})
+	project, _ := ret["id"].(string)
+	require.NotEmpty(t, project, ret)
+	ret = storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": strconv.FormatInt(id, 10), "project_id": project})
+	assert.Empty(t, ret)
+	assert.Equal(t, []string{"Старая"}, projectTitles(t, srv, "project="+project))
+}
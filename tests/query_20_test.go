@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/api"
+	"todo/pkg/db"
+)
+
+func TestParseQuery(t *testing.T) {
+	q, err := api.ParseQuery(`from:01.03.2025 to:20250331 repeat:YES "quarterly report" -draft -"old plan" отчёт 18:00`)
+	require.NoError(t, err)
+	assert.Equal(t, db.Query{
+		From:   "20250301",
+		To:     "20250331",
+		Repeat: db.RepeatYes,
+		Include: []db.Term{
+			{Text: "quarterly report", Exact: true}, {Text: "отчёт"}, {Text: "18:00"},
+		},
+		Exclude: []db.Term{{Text: "draft"}, {Text: "old plan", Exact: true}},
+	}, q)
+
+	// слова с двоеточием, кроме фильтров, — обычные слова
+	q, err = api.ParseQuery(`Note: https://example.com due:01.01.2025 -TODO:`)
+	require.NoError(t, err)
+	assert.Equal(t, db.Query{
+		Include: []db.Term{{Text: "Note:"}, {Text: "https://example.com"}, {Text: "due:01.01.2025"}},
+		Exclude: []db.Term{{Text: "TODO:"}},
+	}, q)
+
+	// дата сама по себе — прежний поиск по дню
+	q, err = api.ParseQuery(`05.03.2025`)
+	require.NoError(t, err)
+	assert.Equal(t, db.Query{From: "20250305", To: "20250305"}, q)
+
+	for search, pos := range map[string]int{
+		`plan "quarterly report`:        5,
+		`from:32.01.2025`:               5,
+		`repeat:maybe`:                  7,
+		`a -from:01.01.2025`:            2,
+		`from:`:                         0,
+		`a TAG:`:                        2,
+		`x - y`:                         2,
+		`""`:                            0,
+		`ab"c`:                          2,
+		`to:01.01.2025 to:02.01.2025`:   14,
+		`from:02.01.2025 to:01.01.2025`: 16,
+		`from:01.01.2025 01.01.2025`:    16,
+	} {
+		_, err := api.ParseQuery(search)
+		var se *api.QuerySyntaxError
+		if assert.ErrorAs(t, err, &se, search) {
+			assert.Equal(t, pos, se.Pos, search)
+		}
+	}
+}
+
+// checkQuery проверяет фильтры языка запросов через API поверх store.
+func checkQuery(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	day := func(n int) time.Time { return time.Now().AddDate(0, 0, n) }
+	storeAddTask(t, srv, map[string]any{"date": day(1).Format(`20060102`), "title": "Quarterly report", "comment": "draft"})
+	storeAddTask(t, srv, map[string]any{"date": day(2).Format(`20060102`), "title": "Quarterly report", "comment": "final version", "repeat": "d 90"})
+	storeAddTask(t, srv, map[string]any{"date": day(3).Format(`20060102`), "title": "Report quarterly numbers", "repeat": "d 30"})
+	storeAddTask(t, srv, map[string]any{"date": day(10).Format(`20060102`), "title": "Планёрка", "repeat": "w 1"})
+
+	find := func(search string) []string {
+		ret := storeRequest(t, srv, http.MethodGet, "api/tasks?search="+url.QueryEscape(search), nil)
+		require.Nil(t, ret["error"], ret)
+		var titles []string
+		for _, it := range ret["tasks"].([]any) {
+			m := it.(map[string]any)
+			titles = append(titles, m["title"].(string)+"/"+m["comment"].(string))
+		}
+		return titles
+	}
+
+	assert.Equal(t, []string{"Quarterly report/draft", "Quarterly report/final version"},
+		find(`"quarterly report"`))
+	assert.Equal(t, []string{"Quarterly report/final version"}, find(`"quarterly report" -draft`))
+	assert.Equal(t, []string{"Report quarterly numbers/"}, find(`repeat:yes quarter -"final version" -план`))
+	assert.Equal(t, []string{"Quarterly report/final version", "Report quarterly numbers/"},
+		find("from:"+day(2).Format("02.01.2006")+" to:"+day(5).Format("20060102")))
+	assert.Equal(t, []string{"Quarterly report/draft"}, find("repeat:no"))
+	assert.Equal(t, []string{"Планёрка/"}, find("-report"))
+
+	// слова с двоеточием ищутся как обычный текст
+	storeAddTask(t, srv, map[string]any{
+		"date": day(4).Format(`20060102`), "title": "Note: купить молоко", "comment": "список на https://example.com/list",
+	})
+	assert.Equal(t, []string{"Note: купить молоко/список на https://example.com/list"}, find("Note:"))
+	assert.Equal(t, []string{"Note: купить молоко/список на https://example.com/list"}, find("https://example.com"))
+
+	ret := storeRequest(t, srv, http.MethodGet, "api/tasks?search="+url.QueryEscape(`"unterminated`), nil)
+	assert.Equal(t, `search: unterminated quote at position 0`, ret["error"])
+	assert.Equal(t, float64(0), ret["position"])
+}
+
+func TestQueryLanguage(t *testing.T) {
+	forEachStore(t, checkQuery)
+}
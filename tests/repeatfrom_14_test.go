@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepeatFromDone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+
+	// задача на через 5 дней, выполнена сегодня: следующий раз — через 7 дней от сегодня
+	id := addTaskValues(t, map[string]any{
+		"date":        now.AddDate(0, 0, 5).Format(`20060102`),
+		"title":       "Полить цветы",
+		"repeat":      "d 7",
+		"repeat_from": "done",
+	})
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]string
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "done", m["repeat_from"])
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 7).Format(`20060102`), task.Date)
+
+	// режим по расписанию: интервал от сохранённой даты
+	id = addTaskValues(t, map[string]any{
+		"date":        now.AddDate(0, 0, 5).Format(`20060102`),
+		"title":       "Оплатить интернет",
+		"repeat":      "d 7",
+		"repeat_from": "schedule",
+	})
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 12).Format(`20060102`), task.Date)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"title":       "Неизвестный режим",
+		"repeat":      "d 7",
+		"repeat_from": "tomorrow",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
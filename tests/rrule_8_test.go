@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/db"
+)
+
+func TestNextDateRRule(t *testing.T) {
+	tbl := []nextDate{
+		{"20240101", "RRULE:FREQ=DAILY;INTERVAL=10", "20240131"},
+		{"20240101", "RRULE:FREQ=WEEKLY;BYDAY=MO,TH", "20240129"},
+		{"20240101", "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=FR", "20240202"},
+		{"20240109", "RRULE:FREQ=MONTHLY;INTERVAL=3;BYDAY=2TU", "20240409"},
+		{"20240101", "RRULE:FREQ=MONTHLY;BYDAY=-1FR", "20240223"},
+		{"20240101", "RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "20240131"},
+		{"20240101", "RRULE:FREQ=MONTHLY;BYMONTHDAY=15,-1", "20240131"},
+		{"20240101", "RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU", "20240331"},
+		{"20240229", "RRULE:FREQ=YEARLY", "20280229"},
+		{"20240120", "RRULE:FREQ=DAILY;COUNT=10", "20240127"},
+		{"20240101", "RRULE:FREQ=DAILY;COUNT=5", ""},
+		{"20240101", "RRULE:FREQ=WEEKLY;UNTIL=20240125", ""},
+		{"20240101", "RRULE:FREQ=HOURLY", ""},
+		{"20240101", "RRULE:FREQ=DAILY;BYDAY=2MO", ""},
+		{"20240101", "RRULE:INTERVAL=2", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+
+	// точки с запятой без экранирования тоже должны доходить до обработчика
+	get, err := getBody("api/nextdate?now=20240126&date=20240101&repeat=RRULE:FREQ=DAILY;INTERVAL=10")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240131", strings.TrimSpace(string(get)))
+}
+
+// checkRRuleCountDone проверяет, что COUNT считается от первой даты серии, а не
+// от даты задачи, которая сдвигается при каждом выполнении: после COUNT
+// выполнений задача уходит в корзину, а правило сохраняется как задано.
+func checkRRuleCountDone(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	id := storeAddTask(t, srv, map[string]any{
+		"date": date, "title": "Три раза", "repeat": "RRULE:FREQ=DAILY;COUNT=3;INTERVAL=2",
+	})
+
+	ret := storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.Equal(t, "RRULE:FREQ=DAILY;COUNT=3;INTERVAL=2", ret["repeat"])
+	assert.Nil(t, ret["repeat_count"])
+
+	for range 2 {
+		assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+id, nil))
+		ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+		require.Nil(t, ret["error"], ret)
+		assert.Equal(t, "RRULE:FREQ=DAILY;COUNT=3;INTERVAL=2", ret["repeat"])
+	}
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+id, nil))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)["error"])
+	assert.Equal(t, []string{"Три раза"}, trashTitles(t, srv))
+
+	// действует меньшее из COUNT и repeat_count, оба сохраняются как заданы
+	id = storeAddTask(t, srv, map[string]any{
+		"date": date, "title": "x", "repeat": "RRULE:FREQ=WEEKLY;COUNT=5;BYDAY=MO", "repeat_count": "2",
+	})
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.Equal(t, "RRULE:FREQ=WEEKLY;COUNT=5;BYDAY=MO", ret["repeat"])
+	assert.Equal(t, "2", ret["repeat_count"])
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+id, nil))
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+id, nil))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)["error"])
+}
+
+func TestRRuleCountDone(t *testing.T) {
+	forEachStore(t, checkRRuleCountDone)
+}
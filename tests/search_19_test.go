@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"todo/pkg/db"
+)
+
+// searchTitles возвращает title и snippet найденных задач в порядке выдачи.
+func searchTitles(t *testing.T, srv *httptest.Server, search string) ([]string, []string) {
+	ret := storeRequest(t, srv, http.MethodGet, "api/tasks?search="+url.QueryEscape(search), nil)
+	list, _ := ret["tasks"].([]any)
+	var titles, snippets []string
+	for _, it := range list {
+		m := it.(map[string]any)
+		titles = append(titles, m["title"].(string))
+		s, _ := m["snippet"].(string)
+		snippets = append(snippets, s)
+	}
+	return titles, snippets
+}
+
+// checkSearch проверяет полнотекстовый поиск через API поверх store.
+func checkSearch(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	storeAddTask(t, srv, map[string]any{"date": date, "title": "Созвон с командой", "comment": "обсудить отчёт за квартал"})
+	storeAddTask(t, srv, map[string]any{"date": date, "title": "Отчёт для бухгалтерии"})
+	id := storeAddTask(t, srv, map[string]any{"date": date, "title": "Купить хлеб", "comment": "и молоко"})
+
+	// регистр не важен, слово ищется по префиксу, совпадение в title важнее
+	titles, snippets := searchTitles(t, srv, "ОТЧЁТ")
+	assert.Equal(t, []string{"Отчёт для бухгалтерии", "Созвон с командой"}, titles)
+	assert.Equal(t, []string{"<mark>Отчёт</mark> для бухгалтерии", "обсудить <mark>отчёт</mark> за квартал"}, snippets)
+
+	titles, _ = searchTitles(t, srv, "отч кварт")
+	assert.Equal(t, []string{"Созвон с командой"}, titles)
+	titles, _ = searchTitles(t, srv, "ётч")
+	assert.Empty(t, titles)
+	titles, _ = searchTitles(t, srv, "!!!")
+	assert.Empty(t, titles)
+
+	// индекс следует за изменениями и удалением задач
+	storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{
+		"id": id, "title": "Купить <сыр>", "comment": "",
+	})
+	titles, snippets = searchTitles(t, srv, "сыр")
+	assert.Equal(t, []string{"Купить <сыр>"}, titles)
+	assert.Equal(t, []string{"Купить &lt;<mark>сыр</mark>&gt;"}, snippets)
+	titles, _ = searchTitles(t, srv, "хлеб")
+	assert.Empty(t, titles)
+
+	storeRequest(t, srv, http.MethodDelete, "api/task?id="+id, nil)
+	titles, _ = searchTitles(t, srv, "сыр")
+	assert.Empty(t, titles)
+}
+
+func TestFullTextSearch(t *testing.T) {
+	forEachStore(t, checkSearch)
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/api"
+	"todo/pkg/db"
+)
+
+// storeRequest выполняет запрос к тестовому серверу srv и разбирает JSON-ответ.
+func storeRequest(t *testing.T, srv *httptest.Server, method, path string, values map[string]any) map[string]any {
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		require.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, srv.URL+"/"+path, bytes.NewReader(data))
+	require.NoError(t, err)
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var m map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	return m
+}
+
+// newStoreServer поднимает API поверх store на httptest-сервере без пароля.
+func newStoreServer(t *testing.T, store db.TaskStore) *httptest.Server {
+	a := api.New(store)
+	a.SetPassword("")
+	srv := httptest.NewServer(a.Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// forEachStore запускает check подтестами memory, sqlite и postgres — каждый
+// на новом пустом хранилище (postgres пропускается без TODO_TEST_DBDSN).
+func forEachStore(t *testing.T, check func(t *testing.T, store db.TaskStore)) {
+	t.Run("memory", func(t *testing.T) { check(t, db.NewMemoryStore()) })
+	t.Run("sqlite", func(t *testing.T) {
+		store, _ := openTestSQLite(t)
+		check(t, store)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		store, _ := openTestPostgres(t)
+		check(t, store)
+	})
+}
+
+// storeAddTask создаёт задачу через API сервера srv и возвращает её идентификатор.
+func storeAddTask(t *testing.T, srv *httptest.Server, values map[string]any) string {
+	ret := storeRequest(t, srv, http.MethodPost, "api/task", values)
+	id, _ := ret["id"].(string)
+	require.NotEmpty(t, id, ret)
+	return id
+}
+
+// openTestSQLite открывает SQLite во временном каталоге теста; вторым
+// значением возвращается путь к файлу БД.
+func openTestSQLite(t *testing.T) (*db.SQLiteStore, string) {
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	store, err := db.OpenSQLite(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store, path
+}
+
+func TestStoresBehaveAlike(t *testing.T) {
+	forEachStore(t, checkStore)
+}
+
+// checkStore прогоняет через API базовый сценарий работы с задачами поверх store.
+func checkStore(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	now := time.Now()
+	date := now.AddDate(0, 0, 1).Format(`20060102`)
+
+	id := storeAddTask(t, srv, map[string]any{
+		"date":         date,
+		"title":        "Отчёт Q3",
+		"comment":      "ежемесячный",
+		"repeat":       "d 7",
+		"repeat_count": "3",
+	})
+	storeAddTask(t, srv, map[string]any{
+		"date":  now.Format(`20060102`),
+		"title": "Позвонить",
+	})
+
+	// сортировка по дате и поиск без учёта регистра
+	ret := storeRequest(t, srv, http.MethodGet, "api/tasks", nil)
+	list, _ := ret["tasks"].([]any)
+	require.Len(t, list, 2)
+	assert.Equal(t, "Позвонить", list[0].(map[string]any)["title"])
+	ret = storeRequest(t, srv, http.MethodGet, "api/tasks?search=q3", nil)
+	assert.Len(t, ret["tasks"], 1)
+	ret = storeRequest(t, srv, http.MethodGet, "api/tasks?search=отчёт", nil)
+	assert.Len(t, ret["tasks"], 1)
+	ret = storeRequest(t, srv, http.MethodGet, "api/tasks?search="+now.Format("02.01.2006"), nil)
+	assert.Len(t, ret["tasks"], 1)
+
+	// выполнение увеличивает счётчик серии
+	storeRequest(t, srv, http.MethodPost, "api/task/done?id="+id, nil)
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.Equal(t, now.AddDate(0, 0, 8).Format(`20060102`), ret["date"])
+	assert.Equal(t, "1", ret["repeat_done"])
+
+	ret = storeRequest(t, srv, http.MethodDelete, "api/task?id="+id, nil)
+	assert.Empty(t, ret)
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.NotEmpty(t, ret["error"])
+}
+
+func TestStoresAreIsolated(t *testing.T) {
+	first := newStoreServer(t, db.NewMemoryStore())
+	second := newStoreServer(t, db.NewMemoryStore())
+
+	storeAddTask(t, first, map[string]any{
+		"date":  time.Now().Format(`20060102`),
+		"title": "Только в первом",
+	})
+
+	ret := storeRequest(t, first, http.MethodGet, "api/tasks", nil)
+	assert.Len(t, ret["tasks"], 1)
+	ret = storeRequest(t, second, http.MethodGet, "api/tasks", nil)
+	assert.Len(t, ret["tasks"], 0)
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/api"
+	"todo/pkg/db"
+)
+
+func TestParseQueryTags(t *testing.T) {
+	q, err := api.ParseQuery(`tag:Work -tag:#home tag:on-call`)
+	require.NoError(t, err)
+	assert.Equal(t, db.Query{Tags: []string{"work", "on-call"}, NotTags: []string{"home"}}, q)
+
+	_, err = api.ParseQuery(`x -tag:a!b`)
+	var se *api.QuerySyntaxError
+	if assert.ErrorAs(t, err, &se) {
+		assert.Equal(t, 7, se.Pos)
+	}
+}
+
+// taggedTitles возвращает title задач /api/tasks с параметрами params.
+func taggedTitles(t *testing.T, srv *httptest.Server, params url.Values) []string {
+	ret := storeRequest(t, srv, http.MethodGet, "api/tasks?"+params.Encode(), nil)
+	require.Nil(t, ret["error"], ret)
+	titles := []string{}
+	for _, it := range ret["tasks"].([]any) {
+		titles = append(titles, it.(map[string]any)["title"].(string))
+	}
+	return titles
+}
+
+// checkTags проверяет метки задач через API поверх store.
+func checkTags(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	date := time.Now().Format(`20060102`)
+	deploy := storeAddTask(t, srv, map[string]any{"date": date, "title": "Выкатка", "tags": []string{"Work", "#oncall", "work"}})
+	storeAddTask(t, srv, map[string]any{"date": date, "title": "Уборка", "tags": []string{"home"}})
+	storeAddTask(t, srv, map[string]any{"date": date, "title": "Без меток"})
+
+	ret := storeRequest(t, srv, http.MethodGet, "api/task?id="+deploy, nil)
+	assert.Equal(t, []any{"oncall", "work"}, ret["tags"])
+	ret = storeRequest(t, srv, http.MethodPost, "api/task", map[string]any{"date": date, "title": "x", "tags": []string{"a b"}})
+	assert.NotEmpty(t, ret["error"])
+
+	assert.Equal(t, []string{"Выкатка"}, taggedTitles(t, srv, url.Values{"tag": {"work", "ONCALL"}}))
+	assert.Empty(t, taggedTitles(t, srv, url.Values{"tag": {"work", "home"}}))
+	assert.Equal(t, []string{"Уборка"}, taggedTitles(t, srv, url.Values{"search": {"tag:home"}}))
+	assert.Equal(t, []string{"Уборка", "Без меток"}, taggedTitles(t, srv, url.Values{"search": {"-tag:work"}}))
+	ret = storeRequest(t, srv, http.MethodGet, "api/tasks?tag=a+b", nil)
+	assert.NotEmpty(t, ret["error"])
+
+	// PUT без tags метки сохраняет, с пустым списком — снимает
+	storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": deploy, "title": "Выкатка v2"})
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+deploy, nil)
+	assert.Equal(t, []any{"oncall", "work"}, ret["tags"])
+
+	ret = storeRequest(t, srv, http.MethodGet, "api/tags", nil)
+	assert.Equal(t, []any{
+		map[string]any{"name": "home", "tasks": float64(1)},
+		map[string]any{"name": "oncall", "tasks": float64(1)},
+		map[string]any{"name": "work", "tasks": float64(1)},
+	}, ret["tags"])
+
+	assert.Empty(t, storeRequest(t, srv, http.MethodPut, "api/tags?name=work&to=Job", nil))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPut, "api/tags?name=job&to=home", nil)["error"])
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPut, "api/tags?name=work&to=x", nil)["error"])
+	assert.Equal(t, []string{"Выкатка v2"}, taggedTitles(t, srv, url.Values{"tag": {"job"}}))
+	assert.Empty(t, storeRequest(t, srv, http.MethodDelete, "api/tags?name=oncall", nil))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodDelete, "api/tags?name=oncall", nil)["error"])
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+deploy, nil)
+	assert.Equal(t, []any{"job"}, ret["tags"])
+
+	storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{"id": deploy, "tags": []string{}})
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+deploy, nil)
+	assert.Nil(t, ret["tags"])
+	ret = storeRequest(t, srv, http.MethodGet, "api/tags", nil)
+	assert.Equal(t, map[string]any{"name": "job", "tasks": float64(0)}, ret["tags"].([]any)[1])
+}
+
+func TestTags(t *testing.T) {
+	forEachStore(t, checkTags)
+}
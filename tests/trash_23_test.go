@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/pkg/api"
+	"todo/pkg/db"
+)
+
+// trashTitles возвращает title задач в корзине в порядке выдачи.
+func trashTitles(t *testing.T, srv *httptest.Server) []string {
+	ret := storeRequest(t, srv, http.MethodGet, "api/trash", nil)
+	list, _ := ret["tasks"].([]any)
+	titles := []string{}
+	for _, it := range list {
+		m := it.(map[string]any)
+		assert.NotEmpty(t, m["deleted"])
+		titles = append(titles, m["title"].(string))
+	}
+	return titles
+}
+
+// checkTrash проверяет корзину через API поверх store.
+func checkTrash(t *testing.T, store db.TaskStore) {
+	a := api.New(store)
+	a.SetPassword("")
+	srv := httptest.NewServer(a.Handler())
+	t.Cleanup(srv.Close)
+
+	date := time.Now().Format(`20060102`)
+	first := storeAddTask(t, srv, map[string]any{"date": date, "title": "Первая"})
+	second := storeAddTask(t, srv, map[string]any{"date": date, "title": "Вторая"})
+	third := storeAddTask(t, srv, map[string]any{"date": date, "title": "Третья"})
+
+	// удаление и выполнение разовой задачи переносят её в корзину
+	assert.Empty(t, storeRequest(t, srv, http.MethodDelete, "api/task?id="+first, nil))
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+second, nil))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodGet, "api/task?id="+first, nil)["error"])
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodDelete, "api/task?id="+first, nil)["error"])
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{
+		"id": first, "date": date, "title": "Изменённая",
+	})["error"])
+	ret := storeRequest(t, srv, http.MethodGet, "api/tasks", nil)
+	assert.Len(t, ret["tasks"], 1)
+	assert.ElementsMatch(t, []string{"Первая", "Вторая"}, trashTitles(t, srv))
+
+	// восстановление
+	assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/trash/restore?id="+first, nil))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPost, "api/trash/restore?id="+first, nil)["error"])
+	ret = storeRequest(t, srv, http.MethodGet, "api/task?id="+first, nil)
+	assert.Equal(t, "Первая", ret["title"])
+	assert.Nil(t, ret["deleted"])
+	assert.Equal(t, []string{"Вторая"}, trashTitles(t, srv))
+
+	// окончательное удаление — только из корзины
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodDelete, "api/trash?id="+first, nil)["error"])
+	assert.Empty(t, storeRequest(t, srv, http.MethodDelete, "api/trash?id="+second, nil))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodPost, "api/trash/restore?id="+second, nil)["error"])
+	assert.Empty(t, trashTitles(t, srv))
+
+	// автоочистка не трогает задачи моложе срока хранения
+	storeRequest(t, srv, http.MethodDelete, "api/task?id="+third, nil)
+	a.SetTrashRetention(24 * time.Hour)
+	n, err := a.PurgeTrash()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = store.PurgeDeleted(time.Now().AddDate(0, 0, 1).UTC().Format(time.RFC3339))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	storeRequest(t, srv, http.MethodDelete, "api/task?id="+first, nil)
+	ret = storeRequest(t, srv, http.MethodDelete, "api/trash?all=1", nil)
+	assert.Equal(t, float64(1), ret["purged"])
+	assert.Empty(t, trashTitles(t, srv))
+	assert.NotEmpty(t, storeRequest(t, srv, http.MethodDelete, "api/trash", nil)["error"])
+}
+
+func TestTrash(t *testing.T) {
+	forEachStore(t, checkTrash)
+}
+
+// у задач из старых БД нет строки task_meta — в корзину они попадают так же
+func TestTrashLegacyRows(t *testing.T) {
+	sqlite, path := openTestSQLite(t)
+	raw, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer raw.Close()
+	res, err := raw.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES ('20240101', 'Старая', '', '')`)
+	require.NoError(t, err)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+
+	srv := newStoreServer(t, sqlite)
+	ret := storeRequest(t, srv, http.MethodDelete, "api/task?id="+strconv.FormatInt(id, 10), nil)
+	assert.Empty(t, ret)
+	assert.Equal(t, []string{"Старая"}, trashTitles(t, srv))
+}
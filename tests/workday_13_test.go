@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"todo/pkg/db"
+)
+
+func TestNextDateWorkdays(t *testing.T) {
+	tbl := []struct {
+		now string
+		nextDate
+	}{
+		{"20241226", nextDate{"20241225", "m 1 | next ru", "20250109"}},
+		{"20250126", nextDate{"20250101", "m 15 | prev ru", "20250214"}},
+		{"20240126", nextDate{"20240120", "d 7 | next", "20240129"}},
+		{"20240126", nextDate{"20240101", "m 28 | prev", "20240228"}},
+		{"20240126", nextDate{"20240101", "RRULE:FREQ=MONTHLY;BYMONTHDAY=3 | next", "20240205"}},
+		{"20250108", nextDate{"20250108", "b 3 ru", "20250113"}},
+		{"20240126", nextDate{"20240126", "b 1", "20240129"}},
+		{"20240126", nextDate{"20240101", "m 1 | sideways", ""}},
+		{"20240126", nextDate{"20240101", "b 0", ""}},
+		{"20240126", nextDate{"20240101", "b 3 xx", ""}},
+		{"20240126", nextDate{"20240101", "b 3 ../ru", ""}},
+		// производственный календарь: перенесённые выходные и рабочие субботы
+		{"20240426", nextDate{"20240426", "b 1 ru", "20240427"}},
+		{"20250430", nextDate{"20250430", "b 1 ru", "20250505"}},
+		{"20250507", nextDate{"20250507", "b 1 ru", "20250512"}},
+		{"20251031", nextDate{"20251031", "b 1 ru", "20251101"}},
+		{"20251230", nextDate{"20251230", "b 1 ru", "20260112"}},
+		{"20250101", nextDate{"20250101", "m 13 6 | next ru", "20250616"}},
+		{"20250101", nextDate{"20250101", "m 3 11 | prev ru", "20251101"}},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=%s&date=%s&repeat=%s",
+			v.now, url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q, %q}`,
+			v.now, v.date, v.repeat, v.want)
+	}
+}
+
+// checkShiftedSeries проверяет, что перенос с выходного не сдвигает серию:
+// интервал отсчитывается от даты по расписанию, а не от перенесённой.
+func checkShiftedSeries(t *testing.T, store db.TaskStore) {
+	srv := newStoreServer(t, store)
+	thu := time.Now().AddDate(0, 0, 7)
+	for thu.Weekday() != time.Thursday {
+		thu = thu.AddDate(0, 0, 1)
+	}
+	day := func(n int) string { return thu.AddDate(0, 0, n).Format(`20060102`) }
+	id := storeAddTask(t, srv, map[string]any{
+		"date": day(0), "title": "Полив", "repeat": "d 3 | next",
+	})
+
+	// чт → вс, перенос на пн → ср (а не чт от понедельника) → сб, перенос на пн
+	for _, want := range [][2]string{{day(4), day(3)}, {day(6), ""}, {day(11), day(9)}} {
+		assert.Empty(t, storeRequest(t, srv, http.MethodPost, "api/task/done?id="+id, nil))
+		ret := storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+		assert.Equal(t, want[0], ret["date"])
+		scheduled, _ := ret["scheduled"].(string)
+		assert.Equal(t, want[1], scheduled)
+	}
+
+	// изменение даты задаёт новое начало серии, а scheduled от клиента не принимается
+	assert.Empty(t, storeRequest(t, srv, http.MethodPut, "api/task", map[string]any{
+		"id": id, "date": day(14), "scheduled": day(1),
+	}))
+	ret := storeRequest(t, srv, http.MethodGet, "api/task?id="+id, nil)
+	assert.Nil(t, ret["scheduled"])
+}
+
+func TestShiftedSeries(t *testing.T) {
+	forEachStore(t, checkShiftedSeries)
+}